@@ -0,0 +1,215 @@
+package distributed_cache
+
+// In this file, you can find AdminHandler, an HTTP handler to inspect and
+// change a running cache from a debug server. It lists the keys of the node,
+// returns a key with its metadata, deletes a key, cleans the cache, changes
+// its settings and returns the statistics and the members. Every request is
+// checked by the AdminAuth, the handler must not be exposed without one.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultAdminPageSize is the number of keys returned by a page of /keys
+const DefaultAdminPageSize = 100
+
+// MaxAdminPageSize is the maximum number of keys returned by a page of /keys
+const MaxAdminPageSize = 1000
+
+// ErrForbidden can be returned by an AdminAuth to answer 403 instead of 401
+var ErrForbidden = errors.New("forbidden")
+
+// AdminAuth checks a request of AdminHandler, the request is answered with
+// 401 when it returns an error, or 403 when the error is ErrForbidden
+type AdminAuth func(r *http.Request) error
+
+// AdminSource is implemented by every cache type
+type AdminSource interface {
+	MetricsSource
+	getCache() *Cache
+}
+
+func (c *Cache) getCache() *Cache {
+	return c
+}
+
+// AdminEntry is a key returned by AdminHandler
+type AdminEntry struct {
+	Key         string      `json:"key"`
+	Value       interface{} `json:"value"`
+	Version     uint64      `json:"version"`
+	Node        string      `json:"node,omitempty"` // node that wrote the entry
+	Conditional bool        `json:"conditional,omitempty"`
+	Expires     *time.Time  `json:"expires,omitempty"` // nil when the entry does not expire
+}
+
+// AdminSettings are the settings changed by AdminHandler, the nil ones do not change
+type AdminSettings struct {
+	MaxEntries *int      `json:"max_entries,omitempty"`
+	TTL        *Duration `json:"ttl,omitempty"`
+}
+
+// AdminKeys is a page of keys returned by AdminHandler,
+// Next is the cursor of the next page, empty on the last page
+type AdminKeys struct {
+	Keys []string `json:"keys"`
+	Next string   `json:"next,omitempty"`
+}
+
+// AdminHandler returns a handler to inspect and change the entries of the node,
+// the paths are relative to where it is mounted, use http.StripPrefix:
+//
+//	GET    /keys?prefix=&cursor=&limit=  keys in order, paginated
+//	GET    /keys/{key}                   value and metadata of a key
+//	DELETE /keys/{key}                   deletes a key in every node
+//	POST   /clean                        removes every key in every node
+//	PUT    /settings                     changes the settings, see AdminSettings
+//	GET    /stats                        statistics of the cache
+//	GET    /members                      members of the cluster
+//
+// Every request is allowed when auth is nil.
+func AdminHandler(source AdminSource, auth AdminAuth) http.Handler {
+	c := source.getCache()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /keys", func(w http.ResponseWriter, r *http.Request) {
+		limit := DefaultAdminPageSize
+		if value := r.URL.Query().Get("limit"); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				writeAdminError(w, http.StatusBadRequest, errors.New("limit must be a positive integer"))
+				return
+			}
+			limit = min(n, MaxAdminPageSize)
+		}
+		writeAdminJSON(w, http.StatusOK, c.adminKeys(r.URL.Query().Get("prefix"), r.URL.Query().Get("cursor"), limit))
+	})
+	mux.HandleFunc("GET /keys/{key...}", func(w http.ResponseWriter, r *http.Request) {
+		entry, exists := c.adminEntry(r.PathValue("key"))
+		if !exists {
+			writeAdminError(w, http.StatusNotFound, fmt.Errorf("key %q not found", r.PathValue("key")))
+			return
+		}
+		writeAdminJSON(w, http.StatusOK, entry)
+	})
+	mux.HandleFunc("DELETE /keys/{key...}", func(w http.ResponseWriter, r *http.Request) {
+		if err := c.Delete(r.PathValue("key")); err != nil {
+			writeAdminError(w, http.StatusInternalServerError, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("POST /clean", func(w http.ResponseWriter, r *http.Request) {
+		c.Clean()
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("PUT /settings", func(w http.ResponseWriter, r *http.Request) {
+		var settings AdminSettings
+		if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+			writeAdminError(w, http.StatusBadRequest, err)
+			return
+		}
+		if settings.MaxEntries != nil {
+			if err := c.Resize(*settings.MaxEntries); err != nil {
+				writeAdminError(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+		if settings.TTL != nil {
+			if err := c.SetTTL(time.Duration(*settings.TTL)); err != nil {
+				writeAdminError(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("GET /stats", func(w http.ResponseWriter, r *http.Request) {
+		writeAdminJSON(w, http.StatusOK, source.Stats())
+	})
+	mux.HandleFunc("GET /members", func(w http.ResponseWriter, r *http.Request) {
+		writeAdminJSON(w, http.StatusOK, c.Members())
+	})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth != nil {
+			if err := auth(r); err != nil {
+				status := http.StatusUnauthorized
+				if errors.Is(err, ErrForbidden) {
+					status = http.StatusForbidden
+				}
+				writeAdminError(w, status, err)
+				return
+			}
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// adminKeys returns the sorted keys with the prefix after the cursor,
+// the expired keys are not returned
+func (c *Cache) adminKeys(prefix, cursor string, limit int) AdminKeys {
+	c.mutex.Lock()
+	expiring, _ := c.baseEntries().(expiring)
+	now := time.Now()
+	keys := make([]string, 0)
+	for key := range c.storage {
+		if !strings.HasPrefix(key, prefix) || (cursor != "" && key <= cursor) {
+			continue
+		}
+		if expiring != nil && now.After(expiring.deadline(key)) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	c.mutex.Unlock()
+	slices.Sort(keys)
+	page := AdminKeys{Keys: keys}
+	if len(keys) > limit {
+		page.Keys = keys[:limit]
+		page.Next = keys[limit-1]
+	}
+	return page
+}
+
+// adminEntry returns a key with its metadata, without filling it
+// or renewing its TTL
+func (c *Cache) adminEntry(key string) (AdminEntry, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	value, exists := c.storage[key]
+	if !exists {
+		return AdminEntry{}, false
+	}
+	entry := AdminEntry{Key: key, Value: value}
+	if expiring, ok := c.baseEntries().(expiring); ok {
+		deadline := expiring.deadline(key)
+		if time.Now().After(deadline) {
+			return AdminEntry{}, false
+		}
+		entry.Expires = &deadline
+	}
+	if meta, ok := c.meta[key]; ok {
+		entry.Version = meta.Version
+		entry.Node = meta.Node.String()
+		entry.Conditional = meta.Conditional
+	}
+	if _, err := json.Marshal(value); err != nil {
+		entry.Value = fmt.Sprintf("%v", value)
+	}
+	return entry, true
+}
+
+func writeAdminJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+func writeAdminError(w http.ResponseWriter, status int, err error) {
+	writeAdminJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,138 @@
+package distributed_cache
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func adminRequest(t *testing.T, handler http.Handler, method, path string, out interface{}) int {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(method, path, nil))
+	if out != nil && recorder.Code == http.StatusOK {
+		if err := json.Unmarshal(recorder.Body.Bytes(), out); err != nil {
+			t.Fatalf("Expected JSON from %s %s, got %v", method, path, err)
+		}
+	}
+	return recorder.Code
+}
+
+func TestAdminHandler_Keys(t *testing.T) {
+	c := NewLRUCacheWithTTL("adminCache", "255.255.255.255", ":12395", 10, time.Hour)
+	defer c.StopListener()
+	for _, key := range []string{"user/1", "user/2", "user/3", "order/1"} {
+		c.Set(key, "value")
+	}
+	handler := AdminHandler(c, nil)
+
+	var page AdminKeys
+	if code := adminRequest(t, handler, "GET", "/keys?prefix=user/&limit=2", &page); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if len(page.Keys) != 2 || page.Keys[0] != "user/1" || page.Next != "user/2" {
+		t.Errorf("Expected the first page of users, got %+v", page)
+	}
+	cursor := page.Next
+	page = AdminKeys{}
+	adminRequest(t, handler, "GET", "/keys?prefix=user/&limit=2&cursor="+cursor, &page)
+	if len(page.Keys) != 1 || page.Keys[0] != "user/3" || page.Next != "" {
+		t.Errorf("Expected the last page of users, got %+v", page)
+	}
+	if code := adminRequest(t, handler, "GET", "/keys?limit=none", nil); code != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d", code)
+	}
+}
+
+func TestAdminHandler_Entry(t *testing.T) {
+	c := NewLRUCacheWithTTL("adminCache", "255.255.255.255", ":12396", 10, time.Hour)
+	defer c.StopListener()
+	c.Set("user/1", "value")
+	handler := AdminHandler(c, nil)
+
+	var entry AdminEntry
+	if code := adminRequest(t, handler, "GET", "/keys/user/1", &entry); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if entry.Value != "value" || entry.Version == 0 || entry.Node != c.Node().String() || entry.Expires == nil {
+		t.Errorf("Expected the value and metadata of user/1, got %+v", entry)
+	}
+	if code := adminRequest(t, handler, "DELETE", "/keys/user/1", nil); code != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d", code)
+	}
+	if code := adminRequest(t, handler, "GET", "/keys/user/1", nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", code)
+	}
+
+	c.Set("user/2", "value")
+	if code := adminRequest(t, handler, "POST", "/clean", nil); code != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d", code)
+	}
+	var stats Stats
+	adminRequest(t, handler, "GET", "/stats", &stats)
+	if stats.Entries != 0 {
+		t.Errorf("Expected no entries after clean, got %d", stats.Entries)
+	}
+	var members []Member
+	adminRequest(t, handler, "GET", "/members", &members)
+	if len(members) == 0 || !members[0].Local {
+		t.Errorf("Expected the local member, got %v", members)
+	}
+}
+
+func TestAdminHandler_Settings(t *testing.T) {
+	c := NewLRUCacheWithTTL("adminCache", "255.255.255.255", ":12508", 10, time.Hour)
+	defer c.StopListener()
+	handler := AdminHandler(c, nil)
+	tests := []struct {
+		body string
+		code int
+	}{
+		{`{"max_entries": 5, "ttl": "1m"}`, http.StatusNoContent},
+		{`{"ttl": 30}`, http.StatusNoContent},
+		{`{"max_entries": -1}`, http.StatusBadRequest},
+		{`not json`, http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("PUT", "/settings", strings.NewReader(test.body)))
+		if recorder.Code != test.code {
+			t.Errorf("Expected %d for %s, got %d", test.code, test.body, recorder.Code)
+		}
+	}
+	if c.MaxEntries != 5 || c.TTL != 30*time.Second {
+		t.Errorf("Expected 5 entries and 30s, got %d %v", c.MaxEntries, c.TTL)
+	}
+}
+
+func TestAdminHandler_Auth(t *testing.T) {
+	c := NewCache("adminCache", "255.255.255.255", ":12397")
+	defer c.StopListener()
+	handler := AdminHandler(c, func(r *http.Request) error {
+		switch r.Header.Get("Authorization") {
+		case "":
+			return errors.New("missing token")
+		case "Bearer reader":
+			if r.Method != http.MethodGet {
+				return ErrForbidden
+			}
+		}
+		return nil
+	})
+
+	if code := adminRequest(t, handler, "GET", "/stats", nil); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401, got %d", code)
+	}
+	for method, expected := range map[string]int{"GET": http.StatusOK, "POST": http.StatusForbidden} {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(method, map[string]string{"GET": "/stats", "POST": "/clean"}[method], nil)
+		request.Header.Set("Authorization", "Bearer reader")
+		handler.ServeHTTP(recorder, request)
+		if recorder.Code != expected {
+			t.Errorf("Expected %d for %s, got %d", expected, method, recorder.Code)
+		}
+	}
+}
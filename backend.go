@@ -0,0 +1,86 @@
+package distributed_cache
+
+// In this file, you can find the Backend interface used to put the cache as a
+// near-cache (L1) in front of a shared store (L2), like Redis.
+// With a Backend the misses are read from the Backend before calling the Filler,
+// the writes go to the Backend first and the other nodes only receive
+// invalidations, so they read the new value from the Backend when they need it.
+
+import (
+	"sync"
+)
+
+// Backend is a shared store behind the cache
+type Backend interface {
+	Get(key string) (interface{}, bool, error) // Get returns the value and true when the key exists
+	Set(key string, value interface{}) error
+	Delete(key string) error
+}
+
+// MemoryBackend is a Backend that keeps the values in memory,
+// it can be shared by many caches of the same process, for tests
+type MemoryBackend struct {
+	mutex  sync.RWMutex
+	values map[string]interface{}
+}
+
+// NewMemoryBackend creates an empty MemoryBackend
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{values: make(map[string]interface{})}
+}
+
+func (m *MemoryBackend) Get(key string) (interface{}, bool, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	value, exists := m.values[key]
+	return value, exists, nil
+}
+
+func (m *MemoryBackend) Set(key string, value interface{}) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.values[key] = value
+	return nil
+}
+
+func (m *MemoryBackend) Delete(key string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.values, key)
+	return nil
+}
+
+// loadBackend reads a missing key from the Backend and keeps it in this node,
+// without sending it to the other nodes. The value is not kept when the key
+// is written or deleted while it is read, it may be older than that write
+func (c *Cache) loadBackend(key string) (interface{}, bool) {
+	c.mutex.Lock()
+	written, deleted := c.lastWrite(key)
+	c.mutex.Unlock()
+	value, found, err := c.Backend.Get(key)
+	if err != nil {
+		c.logError("backend get failed", err, "key", key)
+		return nil, false
+	}
+	if !found || value == nil {
+		return nil, false
+	}
+	c.mutex.Lock()
+	lastWritten, lastDeleted := c.lastWrite(key)
+	if c.owns(key) && lastWritten == written && lastDeleted == deleted {
+		c.getEntries().store(key, value)
+	}
+	c.mutex.Unlock()
+	return value, true
+}
+
+// writeBackend writes a value to the Backend, a nil value deletes the key
+func (c *Cache) writeBackend(key string, value interface{}) error {
+	if c.Backend == nil {
+		return nil
+	}
+	if value == nil {
+		return c.Backend.Delete(key)
+	}
+	return c.Backend.Set(key, value)
+}
@@ -0,0 +1,87 @@
+package distributed_cache
+
+import (
+	"testing"
+)
+
+func TestCache_BackendReadThrough(t *testing.T) {
+	backend := NewMemoryBackend()
+	first := NewCache("nearCache", "255.255.255.255", ":12353")
+	defer first.StopListener()
+	second := NewCache("nearCache", "255.255.255.255", ":12354")
+	defer second.StopListener()
+	first.Backend = backend
+	second.Backend = backend
+
+	first.Set("key", "value1")
+	if value, found, _ := backend.Get("key"); !found || value != "value1" {
+		t.Errorf("Expected value1 in the backend, got %v", value)
+	}
+	if val := second.Get("key"); val != "value1" {
+		t.Errorf("Expected value1 from the backend, got %v", val)
+	}
+
+	// the second node keeps its copy until it receives the invalidation
+	first.Set("key", "value2")
+	if val := second.Get("key"); val != "value1" {
+		t.Errorf("Expected the local copy value1, got %v", val)
+	}
+	second.handleMessage(&message{CacheName: "nearCache", Node: first.node, Key: "key", Op: opInvalidate,
+		Version: 100})
+	if val := second.Get("key"); val != "value2" {
+		t.Errorf("Expected value2 from the backend, got %v", val)
+	}
+}
+
+func TestCache_BackendDelete(t *testing.T) {
+	backend := NewMemoryBackend()
+	near := NewCache("nearCache", "255.255.255.255", ":12355")
+	defer near.StopListener()
+	near.Backend = backend
+	near.SetMany(map[string]interface{}{"key1": "value1", "key2": "value2"})
+	near.DeleteMany([]string{"key1"})
+
+	if _, found, _ := backend.Get("key1"); found {
+		t.Errorf("Expected key1 to be deleted from the backend")
+	}
+	near.Clean()
+	values := near.GetMany([]string{"key1", "key2"})
+	if len(values) != 1 || values["key2"] != "value2" {
+		t.Errorf("Expected only key2 from the backend, got %v", values)
+	}
+}
+
+// blockingBackend is a MemoryBackend whose Get waits to be released
+type blockingBackend struct {
+	*MemoryBackend
+	reading chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingBackend) Get(key string) (interface{}, bool, error) {
+	value, found, err := b.MemoryBackend.Get(key)
+	b.reading <- struct{}{}
+	<-b.release
+	return value, found, err
+}
+
+func TestCache_BackendLoadDoesNotOverwriteWrites(t *testing.T) {
+	backend := &blockingBackend{MemoryBackend: NewMemoryBackend(),
+		reading: make(chan struct{}), release: make(chan struct{})}
+	backend.MemoryBackend.Set("key", "old")
+	near := NewCache("nearCache", "255.255.255.255", ":12533")
+	defer near.StopListener()
+	near.Backend = backend
+
+	loaded := make(chan interface{})
+	go func() { loaded <- near.Get("key") }()
+	<-backend.reading
+	near.Set("key", "new")
+	close(backend.release)
+	if val := <-loaded; val != "old" {
+		t.Errorf("Expected the value read from the backend, got %v", val)
+	}
+	if val := near.Get("key"); val != "new" {
+		t.Errorf("Expected the value of the write, got %v", val)
+	}
+}
@@ -0,0 +1,167 @@
+package distributed_cache
+
+// In this file, you can find the methods GetMany, SetMany and DeleteMany
+// that are used to work with many keys at once.
+// They take the mutex only once and send all the changes to the other nodes
+// together, so the sender packs them in as few datagrams as possible.
+// They are defined in Cache and work with every cache type.
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+// GetMany gets the values of many keys from the cache.
+// The keys that are not found are filled with BatchFiller in a single call,
+// or with Filler for each key when BatchFiller is not set,
+// and the filled values are set in the cache as with SetMany.
+// Only the keys with a value are returned.
+func (c *Cache) GetMany(keys []string) map[string]interface{} {
+	out := make(map[string]interface{}, len(keys))
+	var misses, remote []string
+	c.mutex.Lock()
+	entries := c.getEntries()
+	for _, key := range keys {
+		if c.Partitioned && !c.owns(key) {
+			remote = append(remote, key)
+		} else if value, exists := entries.load(key); exists {
+			out[key] = value
+			c.lookup(true)
+		} else {
+			misses = append(misses, key)
+			c.lookup(false)
+		}
+	}
+	c.mutex.Unlock()
+	for _, key := range remote {
+		if value, ok := c.forwardGet(key); ok {
+			out[key] = value
+		} else {
+			misses = append(misses, key)
+		}
+	}
+	if c.Backend != nil {
+		remaining := misses[:0]
+		for _, key := range misses {
+			if value, ok := c.loadBackend(key); ok {
+				out[key] = value
+			} else {
+				remaining = append(remaining, key)
+			}
+		}
+		misses = remaining
+	}
+	if len(misses) == 0 {
+		return out
+	}
+	filled := c.fillMany(misses)
+	if len(filled) == 0 {
+		return out
+	}
+	if err := c.SetMany(filled); err != nil {
+		c.logError("set of filled keys failed", err, "keys", len(filled))
+	}
+	for key, value := range filled {
+		if value != nil {
+			out[key] = value
+		}
+	}
+	return out
+}
+
+// fillMany gets the values of the missing keys from BatchFiller or Filler
+func (c *Cache) fillMany(keys []string) map[string]interface{} {
+	if c.BatchFiller != nil {
+		start := time.Now()
+		values, err := c.BatchFiller(keys)
+		c.observeFill(start, err)
+		if err != nil {
+			c.logError("batch filler failed", err, "keys", len(keys))
+		}
+		return values
+	}
+	if c.Filler == nil {
+		return nil
+	}
+	values := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		start := time.Now()
+		value, err := c.Filler(key)
+		c.observeFill(start, err)
+		if err != nil {
+			c.logError("filler failed", err, "key", key)
+			continue
+		}
+		values[key] = value
+	}
+	return values
+}
+
+// SetMany sets many values in the cache and sends them to the other nodes
+// together, a nil value deletes the key. The keys that fail to be written to
+// the Backend or the write-through Writer are not changed and their errors returned
+func (c *Cache) SetMany(values map[string]interface{}) error {
+	values, err := c.writeOutMany(values)
+	messages := make([]*message, 0, len(values))
+	c.mutex.Lock()
+	entries := c.getEntries()
+	for _, key := range sortedKeys(values) {
+		value := values[key]
+		message := c.newMessage(key, value)
+		if value == nil || !c.owns(key) {
+			entries.remove(key)
+			message.Version = c.deleted(key)
+		} else {
+			entries.store(key, value)
+			message.Version = c.touch(key, 0, false)
+		}
+		messages = append(messages, c.replicated(message))
+	}
+	c.mutex.Unlock()
+	c.sendMessage(messages...)
+	return err
+}
+
+// DeleteMany deletes many values from the cache
+// and sends the delete messages to the other nodes together, see SetMany
+func (c *Cache) DeleteMany(keys []string) error {
+	var errs []error
+	keys = slices.DeleteFunc(slices.Clone(keys), func(key string) bool {
+		err := c.writeOut(key, nil)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		return err != nil
+	})
+	messages := make([]*message, 0, len(keys))
+	c.mutex.Lock()
+	entries := c.getEntries()
+	for _, key := range keys {
+		entries.remove(key)
+		message := c.newMessage(key, nil)
+		message.Version = c.deleted(key)
+		messages = append(messages, message)
+	}
+	c.mutex.Unlock()
+	c.sendMessage(messages...)
+	return errors.Join(errs...)
+}
+
+// writeOutMany writes the values to the Backend and the Writer,
+// it returns the values that were written and the errors of the others
+func (c *Cache) writeOutMany(values map[string]interface{}) (map[string]interface{}, error) {
+	if c.Backend == nil && c.Writer == nil {
+		return values, nil
+	}
+	written := make(map[string]interface{}, len(values))
+	var errs []error
+	for _, key := range sortedKeys(values) {
+		if err := c.writeOut(key, values[key]); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		written[key] = values[key]
+	}
+	return written, errors.Join(errs...)
+}
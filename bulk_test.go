@@ -0,0 +1,77 @@
+package distributed_cache
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCache_SetManyGetMany(t *testing.T) {
+	cache.SetMany(map[string]interface{}{"bulk1": "value1", "bulk2": "value2"})
+
+	values := cache.GetMany([]string{"bulk1", "bulk2", "bulk3"})
+	expected := map[string]interface{}{"bulk1": "value1", "bulk2": "value2"}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+}
+
+func TestCache_DeleteMany(t *testing.T) {
+	cache.SetMany(map[string]interface{}{"bulk1": "value1", "bulk2": "value2"})
+	cache.DeleteMany([]string{"bulk1", "bulk2"})
+
+	if values := cache.GetMany([]string{"bulk1", "bulk2"}); len(values) != 0 {
+		t.Errorf("Expected no values, got %v", values)
+	}
+}
+
+func TestCache_GetManyBatchFiller(t *testing.T) {
+	var calls [][]string
+	cache.BatchFiller = func(keys []string) (map[string]interface{}, error) {
+		calls = append(calls, keys)
+		values := make(map[string]interface{})
+		for _, key := range keys {
+			values[key] = "filled-" + key
+		}
+		return values, nil
+	}
+	defer func() { cache.BatchFiller = nil }()
+	cache.Set("bulk1", "value1")
+
+	values := cache.GetMany([]string{"bulk1", "bulk4", "bulk5"})
+	if len(calls) != 1 || !reflect.DeepEqual(calls[0], []string{"bulk4", "bulk5"}) {
+		t.Errorf("Expected a single call with the misses, got %v", calls)
+	}
+	if values["bulk1"] != "value1" || values["bulk4"] != "filled-bulk4" || values["bulk5"] != "filled-bulk5" {
+		t.Errorf("Unexpected values %v", values)
+	}
+	if val := cache.Get("bulk5"); val != "filled-bulk5" {
+		t.Errorf("Expected filled-bulk5, got %v", val)
+	}
+	cache.DeleteMany([]string{"bulk1", "bulk4", "bulk5"})
+}
+
+func TestLRUCache_SetManyEviction(t *testing.T) {
+	lruCache.Clean()
+	lruCache.SetMany(map[string]interface{}{"bulk1": "value1", "bulk2": "value2", "bulk3": "value3"})
+
+	values := lruCache.GetMany([]string{"bulk1", "bulk2", "bulk3"})
+	expected := map[string]interface{}{"bulk2": "value2", "bulk3": "value3"}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+}
+
+func TestLRUCacheWithTTL_SetManyGetMany(t *testing.T) {
+	lruCacheWithTTL.Clean()
+	lruCacheWithTTL.SetMany(map[string]interface{}{"bulk1": "value1", "bulk2": "value2"})
+
+	values := lruCacheWithTTL.GetMany([]string{"bulk1", "bulk2"})
+	expected := map[string]interface{}{"bulk1": "value1", "bulk2": "value2"}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+	lruCacheWithTTL.DeleteMany([]string{"bulk1", "bulk2"})
+	if values := lruCacheWithTTL.GetMany([]string{"bulk1", "bulk2"}); len(values) != 0 {
+		t.Errorf("Expected no values, got %v", values)
+	}
+}
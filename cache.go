@@ -3,14 +3,25 @@ package distributed_cache
 import (
 	"context"
 	"github.com/google/uuid"
-	"log"
+	"log/slog"
+	"slices"
 	"sync"
+	"time"
 )
 
 // Cache is a simple cache interface in this file only you can find
 // the Cache struct and the methods Set, Get, Delete and Clean
 // that are used to interact with the cache
 
+// entries is implemented by every cache type to store its entries,
+// the methods must be called with the mutex locked
+type entries interface {
+	load(key string) (interface{}, bool)
+	store(key string, value interface{})
+	remove(key string)
+	removeAll()
+}
+
 // Cache is a simple cache interface, to create a cache you must Use NewCache Method
 type Cache struct {
 	mutex        sync.Mutex
@@ -23,14 +34,104 @@ type Cache struct {
 	Filler func(string) (interface{}, error) // Function to fill the cache
 	// when the key is not found
 	RemoveHook func(string, interface{}) // Function to remove the key from the cache
-	context    context.Context
-	node       uuid.UUID
+	Codec      Codec                     // Codec to encode the values sent to the other nodes, gob when nil
+	// Function to fill the cache with many keys at once in GetMany,
+	// when it is nil Filler is called for each key
+	BatchFiller func([]string) (map[string]interface{}, error)
+	// Compression of the values sent to the other nodes, none by default
+	Compression Compression
+	// Minimum value size in bytes to compress, DefaultCompressionThreshold when 0
+	CompressionThreshold int
+	// Time a message waits to be sent in the same datagram with other messages,
+	// when 0 only the messages that are already queued are batched
+	FlushInterval time.Duration
+	MaxBatchBytes int // Maximum size of a datagram, 1024 bytes when 0
+	// Guarantee of SetIfAbsent, CompareAndSwap and Update, ConsistencyLocal by default
+	Consistency Consistency
+	// Time a strong conditional write waits for conflicting writes, DefaultConflictWindow when 0
+	ConflictWindow time.Duration
+	// Time between the heartbeats sent to the other members, DefaultHeartbeatInterval when 0
+	HeartbeatInterval time.Duration
+	// Partitioned stores each key only in ReplicationFactor owners chosen with a
+	// consistent hash ring of the members, instead of in every node
+	Partitioned       bool
+	ReplicationFactor int           // Owners of each key, DefaultReplicationFactor when 0
+	VirtualNodes      int           // Points of each member in the ring, DefaultVirtualNodes when 0
+	ForwardTimeout    time.Duration // Time Get waits for an owner, DefaultForwardTimeout when 0
+	// What a Set sends to the other nodes, the value with ReplicationFull (default)
+	// or only an invalidation of the key with ReplicationInvalidate
+	Replication ReplicationMode
+	// Shared store behind the cache, the misses are read from it and the writes
+	// go to it before invalidating the copies of the other nodes
+	Backend Backend
+	// Creates the spans of Get, the fillers and the replication, none when nil
+	Tracer Tracer
+	// Logs the errors of the cache, nothing is logged when nil
+	Logger      *slog.Logger
+	LogInterval time.Duration // Minimum time between two logs of the same error, DefaultLogInterval when 0
+	// Persists the local writes, see WriteMode
+	Writer Writer
+	// When the Writer is called, WriteThrough (default) or WriteBehind
+	WriteMode WriteMode
+	// Time between the flushes of the write-behind queue, DefaultWriteBehindInterval when 0
+	WriteBehindInterval time.Duration
+	WriteRetries        int // Retries of a failed write-behind write, DefaultWriteRetries when 0
+	// Function called with the write-behind writes that failed after the retries,
+	// the errors are logged when it is nil
+	DeadLetter func(key string, value interface{}, err error)
+	// Sends the settings changed with Resize and SetTTL to the other nodes
+	ShareSettings bool
+	// Receives the messages without sending heartbeats, so the node is not a member
+	Observer bool
+	// Function called with every message received from the other nodes
+	MessageHook func(Event)
+
+	context context.Context
+	node    uuid.UUID
+	entries entries // the cache type that stores the entries
+	meta    map[string]entryMeta
+	clock   uint64 // Lamport clock of the writes
+	// versions of the deleted keys, kept for the ConflictWindow
+	tombstones map[string]tombstone
+	buried     []string // keys of the tombstones in the order they expire
+	// CRDT values evicted by this node, rebuilt before they change again
+	lost      map[string]bool
+	lostOrder []string
+	pending   map[string]bool // keys with a conditional write of this node in progress
+	// signaled when a key is no longer pending
+	pendingDone *sync.Cond
+
+	members       map[uuid.UUID]Member
+	ring          *hashRing
+	membersMutex  sync.RWMutex
+	requests      map[uint64]chan *message // Get requests waiting for an owner
+	requestsMutex sync.Mutex
+	sender        *sender
+	senderOnce    sync.Once
+
+	writeBehind     *writeBehind
+	writeBehindOnce sync.Once
+	snapshotDone    chan struct{}  // closed after the last snapshot of EnableSnapshots
+	wal             *wal           // write-ahead log of EnableLog
+	counters        counters       // statistics returned by Stats
+	sizes           map[string]int // approximate size of each entry, see sizeOf
+	bytes           int            // sum of sizes
+	logLimiter      logLimiter
+	key             []byte      // shared key to sign the datagrams, see WithSecurity
+	replays         replayGuard // signed datagrams already received
 }
 
 func (c *Cache) getNode() uuid.UUID {
 	return c.node
 }
 
+func (c *Cache) getCodec() Codec {
+	if c.Codec == nil {
+		return defaultCodec
+	}
+	return c.Codec
+}
+
 func (c *Cache) getAddress() string {
 	return c.Address
 }
@@ -39,74 +140,267 @@ func (c *Cache) getName() string {
 	return c.Name
 }
 
+func (c *Cache) getKey() []byte {
+	return c.key
+}
+
+func (c *Cache) getReplays() *replayGuard {
+	return &c.replays
+}
+
 func (c *Cache) clean() {
 	c.mutex.Lock()
-	if c.RemoveHook != nil {
-		for key, value := range c.storage {
-			go c.RemoveHook(key, value)
-		}
-	}
-	c.storage = make(map[string]interface{})
+	c.getEntries().removeAll()
 	c.mutex.Unlock()
 }
 
-func (c *Cache) set(key string, value interface{}) {
-	c.mutex.Lock()
-	c.storage[key] = value
-	c.mutex.Unlock()
+// getEntries returns the cache type that stores the entries,
+// wrapped to append the changes to the log when it is enabled.
+// The mutex must be locked
+func (c *Cache) getEntries() entries {
+	if c.wal != nil {
+		return loggedEntries{entries: c.baseEntries(), cache: c}
+	}
+	return c.baseEntries()
 }
 
-func (c *Cache) delete(key string) {
-	c.mutex.Lock()
+// baseEntries returns the cache type that stores the entries
+func (c *Cache) baseEntries() entries {
+	if c.entries == nil {
+		return c
+	}
+	return c.entries
+}
+
+// load returns the value of a key, the mutex must be locked
+func (c *Cache) load(key string) (interface{}, bool) {
+	value, exists := c.storage[key]
+	return value, exists
+}
+
+// store stores the value of a key, the mutex must be locked
+func (c *Cache) store(key string, value interface{}) {
+	c.putStorage(key, value)
+}
+
+// remove removes a key, the mutex must be locked
+func (c *Cache) remove(key string) {
 	if c.RemoveHook != nil {
 		go c.RemoveHook(key, c.storage[key])
 	}
-	delete(c.storage, key)
-	c.mutex.Unlock()
+	c.deleteStorage(key)
+	delete(c.meta, key)
 }
 
-// Set sets a value in the cache and sends it to the other nodes
-func (c *Cache) Set(key string, value interface{}) {
-	if value == nil {
-		c.Delete(key)
+// removeAll removes all the keys, the mutex must be locked
+func (c *Cache) removeAll() {
+	c.runRemoveHooks(sortedKeys(c.storage), c.storage)
+	c.resetStorage()
+	c.meta = nil
+	c.lost, c.lostOrder = nil, nil
+}
+
+// runRemoveHooks calls the RemoveHook for the given keys in order,
+// the hooks run in a single goroutine, so they do not block the cache
+func (c *Cache) runRemoveHooks(keys []string, values map[string]interface{}) {
+	if c.RemoveHook == nil || len(keys) == 0 {
 		return
 	}
-	c.sendSet(key, value)
-	c.set(key, value)
+	hook := c.RemoveHook
+	removed := make([]interface{}, len(keys))
+	for i, key := range keys {
+		removed[i] = values[key]
+	}
+	go func() {
+		for i, key := range keys {
+			hook(key, removed[i])
+		}
+	}()
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// Set sets a value in the cache and sends it to the other nodes,
+// it returns the error of the Backend or the write-through Writer,
+// in which case the cache is not changed
+func (c *Cache) Set(key string, value interface{}) error {
+	return c.SetContext(context.Background(), key, value)
+}
+
+// SetContext is Set with the context of the caller, the span of the write
+// is a child of the span of ctx and the other nodes apply it in child spans
+func (c *Cache) SetContext(ctx context.Context, key string, value interface{}) error {
+	return c.set(ctx, key, value, 0)
+}
+
+// set sets a value and its time-to-live, the TTL of the cache when it is 0
+func (c *Cache) set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	if value == nil {
+		return c.DeleteContext(ctx, key)
+	}
+	ctx, span := c.startWrite(ctx, SpanSet, key)
+	defer span.End()
+	expiring, ok := c.baseEntries().(expirable)
+	if ttl > 0 && !ok {
+		span.RecordError(ErrNoExpiration)
+		return ErrNoExpiration
+	}
+	if err := c.writeOut(key, value); err != nil {
+		span.RecordError(err)
+		return err
+	}
+	c.lockTraced(span)
+	write := c.newMessage(key, value)
+	if c.owns(key) {
+		c.getEntries().store(key, value)
+		write.Version = c.touch(key, 0, false)
+	} else {
+		c.getEntries().remove(key)
+		write.Version = c.tick()
+	}
+	messages := []*message{c.replicated(write)}
+	if ttl > 0 {
+		c.expire(expiring, key, ttl)
+		messages = append(messages, c.expireMessage(key, ttl))
+	}
+	c.mutex.Unlock()
+	c.sendMessageContext(ctx, messages...)
+	return nil
 }
 
-// Get gets a value from the cache
+// Get gets a value from the cache,
+// in the partitioned mode the owners are asked for the keys of other nodes
 func (c *Cache) Get(key string) interface{} {
+	return c.GetContext(context.Background(), key)
+}
+
+// GetContext is Get with the context of the caller,
+// the span of the Get is a child of the span of ctx
+func (c *Cache) GetContext(ctx context.Context, key string) interface{} {
+	ctx, span := c.getTracer().Start(ctx, SpanGet)
+	defer span.End()
+	span.SetAttributes(Attribute{Key: "dcache.cache", Value: c.Name}, Attribute{Key: "dcache.key", Value: key})
+	if c.Partitioned && !c.owns(key) {
+		if value, ok := c.forwardGet(key); ok {
+			span.SetAttributes(Attribute{Key: "dcache.forwarded", Value: true})
+			return value
+		}
+	}
+	return c.getLocal(ctx, span, key)
+}
+
+// getLocal gets a value from this node, filling it when the key is not found,
+// span is the span of the Get
+func (c *Cache) getLocal(ctx context.Context, span Span, key string) interface{} {
 	var err error
-	c.mutex.Lock()
-	out, exists := c.storage[key]
+	c.lockTraced(span)
+	out, exists := c.getEntries().load(key)
 	c.mutex.Unlock()
+	c.lookup(exists)
+	span.SetAttributes(Attribute{Key: "dcache.hit", Value: exists})
+	if !exists && c.Backend != nil {
+		if value, found := c.loadBackend(key); found {
+			return value
+		}
+	}
 	if !exists && c.Filler != nil {
+		_, fillSpan := c.getTracer().Start(ctx, SpanFiller)
+		start := time.Now()
 		out, err = c.Filler(key)
+		c.observeFill(start, err)
 		if err != nil {
-			log.Println(err)
+			fillSpan.RecordError(err)
+			c.logError("filler failed", err, "key", key)
+		}
+		fillSpan.End()
+		if err := c.SetContext(ctx, key, out); err != nil {
+			c.logError("set of filled key failed", err, "key", key)
 		}
-		c.Set(key, out)
 	}
 	return out
 }
 
+// Contains returns true when this node stores a value for the key that has
+// not expired. Unlike Get, it does not fill the key, renew its TTL or count a lookup
+func (c *Cache) Contains(key string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.contains(key)
+}
+
+// contains returns true when the key is stored and has not expired,
+// the mutex must be locked
+func (c *Cache) contains(key string) bool {
+	if _, exists := c.storage[key]; !exists {
+		return false
+	}
+	deadline := c.deadlineOf(key)
+	return deadline.IsZero() || time.Now().Before(deadline)
+}
+
 // Delete deletes a value from the cache
-// and sends the delete message to the other nodes
-func (c *Cache) Delete(key string) {
-	c.sendDelete(key)
-	c.delete(key)
+// and sends the delete message to the other nodes,
+// it returns the error of the Backend or the write-through Writer
+func (c *Cache) Delete(key string) error {
+	return c.DeleteContext(context.Background(), key)
+}
+
+// DeleteContext is Delete with the context of the caller, see SetContext
+func (c *Cache) DeleteContext(ctx context.Context, key string) error {
+	_, err := c.deleteKey(ctx, key)
+	return err
+}
+
+// Remove is Delete that also returns true when this node stored a value
+// for the key that had not expired, checked in the same lock that removes it
+func (c *Cache) Remove(key string) (bool, error) {
+	return c.deleteKey(context.Background(), key)
+}
+
+// deleteKey deletes a key and returns true when it was stored in this node
+func (c *Cache) deleteKey(ctx context.Context, key string) (bool, error) {
+	ctx, span := c.startWrite(ctx, SpanDelete, key)
+	defer span.End()
+	if err := c.writeOut(key, nil); err != nil {
+		span.RecordError(err)
+		return false, err
+	}
+	c.lockTraced(span)
+	existed := c.contains(key)
+	c.getEntries().remove(key)
+	message := c.newMessage(key, nil)
+	message.Version = c.deleted(key)
+	c.mutex.Unlock()
+	c.sendMessageContext(ctx, message)
+	return existed, nil
 }
 
 // Clean deletes all values from the cache
 // and sends the sendClean message to the other nodes
 func (c *Cache) Clean() {
+	c.CleanContext(context.Background())
+}
+
+// CleanContext is Clean with the context of the caller, see SetContext
+func (c *Cache) CleanContext(ctx context.Context) {
+	ctx, span := c.getTracer().Start(ctx, SpanClean)
+	defer span.End()
+	span.SetAttributes(Attribute{Key: "dcache.cache", Value: c.Name})
 	c.clean()
-	c.sendClean()
+	c.sendClean(ctx)
 }
 
 // NewCache creates a new Cache with the given name and address
-// It also starts a listener to receive messages from other nodes
+// It also starts a listener to receive messages from other nodes.
+// New is preferred, it validates the options and returns the errors
 func NewCache(name, broadcast, address string) *Cache {
 	ctx, cancel := context.WithCancel(context.Background())
 	c := &Cache{
@@ -119,6 +413,8 @@ func NewCache(name, broadcast, address string) *Cache {
 		context:      ctx,
 		node:         uuid.New(),
 	}
+	c.entries = c
 	go startListener(c, ctx)
+	go c.startHeartbeat(ctx)
 	return c
 }
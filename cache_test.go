@@ -1,6 +1,7 @@
 package distributed_cache
 
 import (
+	"sync"
 	"testing"
 	"time"
 )
@@ -55,13 +56,20 @@ func TestCache_Clean(t *testing.T) {
 
 func TestCache_Remove(t *testing.T) {
 	var removedKeys []string
+	var mutex sync.Mutex
 	cache.Set("key1", "value1")
 	cache.Set("key2", "value2")
 	cache.RemoveHook = func(key string, value interface{}) {
+		mutex.Lock()
+		defer mutex.Unlock()
 		removedKeys = append(removedKeys, key)
 	}
+	// the other tests share the cache
+	defer func() { cache.RemoveHook = nil }()
 	cache.Clean()
 	time.Sleep(5 * time.Second)
+	mutex.Lock()
+	defer mutex.Unlock()
 	if len(removedKeys) != 2 {
 		t.Errorf("Expected 2 removed keys, got %v", len(removedKeys))
 	}
@@ -72,3 +80,48 @@ func TestCache_Remove(t *testing.T) {
 		t.Errorf("Expected key2, got %v", removedKeys[1])
 	}
 }
+
+func TestCache_Contains(t *testing.T) {
+	fills := 0
+	c, err := New("containsCache", WithTransport("", ":12511"), WithTTL(time.Hour),
+		WithFiller(func(key string) (interface{}, error) {
+			fills++
+			return "filled", nil
+		}))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer c.Close()
+	c.Set("key", "value")
+	if !c.Contains("key") || c.Contains("missing") {
+		t.Errorf("Contains() = %v, %v, want true, false", c.Contains("key"), c.Contains("missing"))
+	}
+	if fills != 0 {
+		t.Errorf("fills = %d, want 0", fills)
+	}
+	if stats := c.Stats(); stats.Hits != 0 || stats.Misses != 0 {
+		t.Errorf("Stats() = %+v, want no lookups", stats)
+	}
+	if _, err := c.Expire("key", time.Nanosecond); err != nil {
+		t.Fatalf("Expire() error = %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	if c.Contains("key") {
+		t.Error("Contains() = true for an expired key")
+	}
+}
+
+func TestCache_RemoveReportsExisting(t *testing.T) {
+	c := NewCache("removeCache", "255.255.255.255", ":12532")
+	defer c.StopListener()
+	c.Set("key", "value")
+	if existed, err := c.Remove("key"); !existed || err != nil {
+		t.Errorf("Remove() = %v, %v, want true, nil", existed, err)
+	}
+	if existed, err := c.Remove("key"); existed || err != nil {
+		t.Errorf("Remove() = %v, %v, want false, nil", existed, err)
+	}
+	if val := c.Get("key"); val != nil {
+		t.Errorf("Expected key to be removed, got %v", val)
+	}
+}
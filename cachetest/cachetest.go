@@ -0,0 +1,117 @@
+// Package cachetest has the conformance tests of the cache types,
+// the tests that every implementation of DistributedCache must pass.
+package cachetest
+
+// In this file, you can find Run, that runs the conformance tests with the
+// caches created by a function. Each test creates its own cache, so the
+// function must return a cache that is not shared with other nodes.
+
+import (
+	distributed_cache "github.com/diogenes-moreira/distributed-cache"
+	"sync"
+	"testing"
+)
+
+// Run runs the conformance tests with the caches created by newCache,
+// the caches are closed at the end of each test
+func Run(t *testing.T, newCache func(t *testing.T) distributed_cache.DistributedCache) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, c distributed_cache.DistributedCache)
+	}{
+		{"SetGet", testSetGet},
+		{"Overwrite", testOverwrite},
+		{"Delete", testDelete},
+		{"Clean", testClean},
+		{"Stats", testStats},
+		{"Concurrent", testConcurrent},
+		{"Close", testClose},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newCache(t)
+			defer c.Close()
+			test.test(t, c)
+		})
+	}
+}
+
+func testSetGet(t *testing.T, c distributed_cache.DistributedCache) {
+	if err := c.Set("key", "value"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if val := c.Get("key"); val != "value" {
+		t.Errorf("Expected value, got %v", val)
+	}
+	if val := c.Get("missing"); val != nil {
+		t.Errorf("Expected nil for a missing key, got %v", val)
+	}
+}
+
+func testOverwrite(t *testing.T, c distributed_cache.DistributedCache) {
+	c.Set("key", "value1")
+	c.Set("key", "value2")
+	if val := c.Get("key"); val != "value2" {
+		t.Errorf("Expected value2, got %v", val)
+	}
+}
+
+func testDelete(t *testing.T, c distributed_cache.DistributedCache) {
+	c.Set("key", "value")
+	if err := c.Delete("key"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if val := c.Get("key"); val != nil {
+		t.Errorf("Expected nil after delete, got %v", val)
+	}
+	if err := c.Delete("missing"); err != nil {
+		t.Errorf("Expected no error deleting a missing key, got %v", err)
+	}
+}
+
+func testClean(t *testing.T, c distributed_cache.DistributedCache) {
+	c.Set("key1", "value1")
+	c.Set("key2", "value2")
+	c.Clean()
+	for _, key := range []string{"key1", "key2"} {
+		if val := c.Get(key); val != nil {
+			t.Errorf("Expected nil for %s after clean, got %v", key, val)
+		}
+	}
+	if entries := c.Stats().Entries; entries != 0 {
+		t.Errorf("Expected no entries after clean, got %d", entries)
+	}
+}
+
+func testStats(t *testing.T, c distributed_cache.DistributedCache) {
+	c.Set("key", "value")
+	c.Get("key")
+	c.Get("missing")
+	stats := c.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Entries != 1 {
+		t.Errorf("Expected 1 hit, 1 miss and 1 entry, got %+v", stats)
+	}
+}
+
+func testConcurrent(t *testing.T, c distributed_cache.DistributedCache) {
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.Set("key", j)
+				c.Get("key")
+			}
+			c.Delete("key")
+		}()
+	}
+	wg.Wait()
+}
+
+func testClose(t *testing.T, c distributed_cache.DistributedCache) {
+	c.Set("key", "value")
+	if err := c.Close(); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
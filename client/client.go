@@ -0,0 +1,501 @@
+// Package client is a client of dcache-server, the server of named caches.
+// Client has the methods of the caches of distributed_cache (Get, Set,
+// Delete, Clean, Stats, GetMany, SetMany, DeleteMany and Expire) and implements
+// DistributedCache, so a service can use an embedded cache or a server by
+// changing its constructor.
+// The values are stored as strings in the server: the strings and the byte
+// slices are sent as they are and the other values as JSON, Get returns strings.
+package client
+
+// In this file, you can find the Client, with a pool of connections to the
+// server. The commands of GetMany, SetMany and DeleteMany are pipelined in a
+// single round trip and the commands that fail because of the network are
+// retried in a new connection.
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	distributed_cache "github.com/diogenes-moreira/distributed-cache"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults of the options
+const (
+	DefaultPoolSize    = 10
+	DefaultTimeout     = time.Second
+	DefaultDialTimeout = time.Second
+	DefaultRetries     = 2
+)
+
+// retryDelay is the time between two attempts of a command
+const retryDelay = 10 * time.Millisecond
+
+// ErrClosed is returned by the commands of a closed Client
+var ErrClosed = errors.New("client closed")
+
+var _ distributed_cache.DistributedCache = (*Client)(nil)
+
+// Client is a client of dcache-server, safe for concurrent use
+type Client struct {
+	address string
+	options options
+	idle    chan *conn    // connections waiting for a command
+	slots   chan struct{} // one for each open connection
+	done    chan struct{} // closed by Close
+	once    sync.Once
+}
+
+// conn is a connection to the server
+type conn struct {
+	net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+}
+
+// Option configures a Client created with New
+type Option func(*options) error
+
+// options are the settings of New
+type options struct {
+	poolSize    int
+	timeout     time.Duration
+	dialTimeout time.Duration
+	retries     int
+	database    string
+	logger      *slog.Logger
+}
+
+// WithPoolSize sets the maximum number of connections to the server, DefaultPoolSize by default
+func WithPoolSize(n int) Option {
+	return func(o *options) error {
+		if n <= 0 {
+			return &distributed_cache.ConfigError{Field: "pool size", Reason: "must be positive"}
+		}
+		o.poolSize = n
+		return nil
+	}
+}
+
+// WithTimeout sets the time a command waits for the server, DefaultTimeout by default,
+// the deadline of the context is used when it is earlier
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) error {
+		if timeout <= 0 {
+			return &distributed_cache.ConfigError{Field: "timeout", Reason: "must be positive"}
+		}
+		o.timeout = timeout
+		return nil
+	}
+}
+
+// WithDialTimeout sets the time to connect to the server, DefaultDialTimeout by default
+func WithDialTimeout(timeout time.Duration) Option {
+	return func(o *options) error {
+		if timeout <= 0 {
+			return &distributed_cache.ConfigError{Field: "dial timeout", Reason: "must be positive"}
+		}
+		o.dialTimeout = timeout
+		return nil
+	}
+}
+
+// WithRetries sets the number of times a command that failed because of
+// the network is retried, DefaultRetries by default
+func WithRetries(retries int) Option {
+	return func(o *options) error {
+		if retries < 0 {
+			return &distributed_cache.ConfigError{Field: "retries", Reason: "must not be negative"}
+		}
+		o.retries = retries
+		return nil
+	}
+}
+
+// WithDatabase selects a cache of the server by name or index, the first one by default
+func WithDatabase(name string) Option {
+	return func(o *options) error {
+		if name == "" {
+			return &distributed_cache.ConfigError{Field: "database", Reason: "must not be empty"}
+		}
+		o.database = name
+		return nil
+	}
+}
+
+// WithLogger logs the errors of the methods that do not return them, Get and Clean
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) error {
+		o.logger = logger
+		return nil
+	}
+}
+
+// New creates a client of the server at address, the connections are
+// opened when they are needed
+func New(address string, opts ...Option) (*Client, error) {
+	if address == "" {
+		return nil, &distributed_cache.ConfigError{Field: "address", Reason: "must not be empty"}
+	}
+	o := options{poolSize: DefaultPoolSize, timeout: DefaultTimeout, dialTimeout: DefaultDialTimeout,
+		retries: DefaultRetries}
+	for _, opt := range opts {
+		if err := opt(&o); err != nil {
+			return nil, err
+		}
+	}
+	return &Client{
+		address: address,
+		options: o,
+		idle:    make(chan *conn, o.poolSize),
+		slots:   make(chan struct{}, o.poolSize),
+		done:    make(chan struct{}),
+	}, nil
+}
+
+// Get gets a value from the server, nil when the key is not found or the command fails
+func (c *Client) Get(key string) interface{} {
+	return c.GetContext(context.Background(), key)
+}
+
+// GetContext is Get with a context
+func (c *Client) GetContext(ctx context.Context, key string) interface{} {
+	value, found, err := c.Fetch(ctx, key)
+	if err != nil {
+		c.logError("get failed", err, "key", key)
+	}
+	if !found {
+		return nil
+	}
+	return value
+}
+
+// Fetch gets a value from the server, it returns false when the key is not found
+func (c *Client) Fetch(ctx context.Context, key string) (string, bool, error) {
+	results, err := c.do(ctx, []string{"GET", key})
+	if err != nil {
+		return "", false, err
+	}
+	if results[0].err != nil {
+		return "", false, results[0].err
+	}
+	value, found := results[0].value.(string)
+	return value, found, nil
+}
+
+// Set sets a value in the server
+func (c *Client) Set(key string, value interface{}) error {
+	return c.SetContext(context.Background(), key, value)
+}
+
+// SetContext is Set with a context
+func (c *Client) SetContext(ctx context.Context, key string, value interface{}) error {
+	encoded, err := encode(value)
+	if err != nil {
+		return err
+	}
+	return c.check(c.do(ctx, []string{"SET", key, encoded}))
+}
+
+// Delete deletes a key from the server
+func (c *Client) Delete(key string) error {
+	return c.check(c.do(context.Background(), []string{"DEL", key}))
+}
+
+// Clean removes every key of the cache, the errors are logged
+func (c *Client) Clean() {
+	if err := c.CleanContext(context.Background()); err != nil {
+		c.logError("clean failed", err)
+	}
+}
+
+// CleanContext removes every key of the cache
+func (c *Client) CleanContext(ctx context.Context) error {
+	return c.check(c.do(ctx, []string{"FLUSHDB"}))
+}
+
+// Stats returns the stats of the cache in the server, the errors are logged
+func (c *Client) Stats() distributed_cache.Stats {
+	stats, err := c.StatsContext(context.Background())
+	if err != nil {
+		c.logError("stats failed", err)
+	}
+	return stats
+}
+
+// StatsContext returns the stats of the cache in the server, read with INFO
+func (c *Client) StatsContext(ctx context.Context) (distributed_cache.Stats, error) {
+	var stats distributed_cache.Stats
+	results, err := c.do(ctx, []string{"INFO"})
+	if err != nil {
+		return stats, err
+	}
+	if results[0].err != nil {
+		return stats, results[0].err
+	}
+	info, _ := results[0].value.(string)
+	fields := map[string]*uint64{"keyspace_hits": &stats.Hits, "keyspace_misses": &stats.Misses,
+		"fills": &stats.Fills, "fill_errors": &stats.FillErrors,
+		"evicted_keys": &stats.Evictions.Capacity, "expired_keys": &stats.Evictions.Expired}
+	for _, line := range strings.Split(info, "\r\n") {
+		name, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		switch name {
+		case "keys":
+			stats.Entries = int(n)
+		case "used_memory":
+			stats.Bytes = int(n)
+		default:
+			if field, ok := fields[name]; ok {
+				*field = n
+			}
+		}
+	}
+	return stats, nil
+}
+
+// Expire sets the time-to-live of a key, rounded up to milliseconds, the key is
+// removed at once when ttl is not positive. It returns false when the key is not found
+func (c *Client) Expire(key string, ttl time.Duration) (bool, error) {
+	milliseconds := int64(0)
+	if ttl > 0 {
+		milliseconds = int64((ttl + time.Millisecond - 1) / time.Millisecond)
+	}
+	results, err := c.do(context.Background(), []string{"PEXPIRE", key, strconv.FormatInt(milliseconds, 10)})
+	if err != nil {
+		return false, err
+	}
+	if results[0].err != nil {
+		return false, results[0].err
+	}
+	return results[0].value == int64(1), nil
+}
+
+// GetMany gets the values of many keys in a single round trip,
+// only the keys with a value are returned
+func (c *Client) GetMany(keys []string) map[string]interface{} {
+	commands := make([][]string, len(keys))
+	for i, key := range keys {
+		commands[i] = []string{"GET", key}
+	}
+	out := make(map[string]interface{}, len(keys))
+	results, err := c.do(context.Background(), commands...)
+	if err != nil {
+		c.logError("get many failed", err, "keys", len(keys))
+		return out
+	}
+	for i, result := range results {
+		if value, found := result.value.(string); found {
+			out[keys[i]] = value
+		}
+	}
+	return out
+}
+
+// SetMany sets many values in a single round trip
+func (c *Client) SetMany(values map[string]interface{}) error {
+	commands := make([][]string, 0, len(values))
+	for key, value := range values {
+		encoded, err := encode(value)
+		if err != nil {
+			return err
+		}
+		commands = append(commands, []string{"SET", key, encoded})
+	}
+	return c.check(c.do(context.Background(), commands...))
+}
+
+// DeleteMany deletes many keys in a single command
+func (c *Client) DeleteMany(keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return c.check(c.do(context.Background(), append([]string{"DEL"}, keys...)))
+}
+
+// Close closes the connections, the commands that are running finish
+func (c *Client) Close() error {
+	c.once.Do(func() {
+		close(c.done)
+	})
+	for {
+		select {
+		case conn := <-c.idle:
+			conn.Close()
+			<-c.slots
+		default:
+			return nil
+		}
+	}
+}
+
+// check returns the first error of the results
+func (c *Client) check(results []result, err error) error {
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		if result.err != nil {
+			return result.err
+		}
+	}
+	return nil
+}
+
+// do sends the commands in a single round trip and returns their results,
+// the commands are sent again in a new connection when the network fails
+func (c *Client) do(ctx context.Context, commands ...[]string) ([]result, error) {
+	if len(commands) == 0 {
+		return nil, nil
+	}
+	var err error
+	for attempt := 0; attempt <= c.options.retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(retryDelay):
+			}
+		}
+		var conn *conn
+		if conn, err = c.get(ctx); err != nil {
+			if errors.Is(err, ErrClosed) || ctx.Err() != nil {
+				return nil, err
+			}
+			continue
+		}
+		var results []result
+		if results, err = c.roundTrip(ctx, conn, commands); err == nil {
+			c.put(conn)
+			return results, nil
+		}
+		c.discard(conn)
+		if ctx.Err() != nil || errors.Is(err, errProtocol) {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+// roundTrip writes the commands and reads their replies
+func (c *Client) roundTrip(ctx context.Context, conn *conn, commands [][]string) ([]result, error) {
+	deadline := time.Now().Add(c.options.timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	for _, command := range commands {
+		writeCommand(conn.writer, command...)
+	}
+	if err := conn.writer.Flush(); err != nil {
+		return nil, err
+	}
+	results := make([]result, len(commands))
+	for i := range results {
+		var err error
+		if results[i], err = readReply(conn.reader); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// get returns an idle connection or opens a new one,
+// it waits for a connection when the pool is full
+func (c *Client) get(ctx context.Context) (*conn, error) {
+	select {
+	case <-c.done:
+		return nil, ErrClosed
+	case conn := <-c.idle:
+		return conn, nil
+	default:
+	}
+	select {
+	case <-c.done:
+		return nil, ErrClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case conn := <-c.idle:
+		return conn, nil
+	case c.slots <- struct{}{}:
+		conn, err := c.dial(ctx)
+		if err != nil {
+			<-c.slots
+			return nil, err
+		}
+		return conn, nil
+	}
+}
+
+// dial opens a connection and selects the database
+func (c *Client) dial(ctx context.Context) (*conn, error) {
+	dialer := net.Dialer{Timeout: c.options.dialTimeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", c.address)
+	if err != nil {
+		return nil, err
+	}
+	conn := &conn{Conn: netConn, reader: bufio.NewReader(netConn), writer: bufio.NewWriter(netConn)}
+	if c.options.database != "" {
+		results, err := c.roundTrip(ctx, conn, [][]string{{"SELECT", c.options.database}})
+		if err == nil {
+			err = results[0].err
+		}
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("selecting %q: %w", c.options.database, err)
+		}
+	}
+	return conn, nil
+}
+
+// put returns a connection to the pool
+func (c *Client) put(conn *conn) {
+	select {
+	case <-c.done:
+		c.discard(conn)
+	default:
+		c.idle <- conn
+	}
+}
+
+// discard closes a connection and frees its slot
+func (c *Client) discard(conn *conn) {
+	conn.Close()
+	<-c.slots
+}
+
+func (c *Client) logError(msg string, err error, args ...any) {
+	if c.options.logger != nil {
+		c.options.logger.Error(msg, append(args, "server", c.address, "error", err)...)
+	}
+}
+
+// encode encodes a value as a string, the values that are not strings as JSON
+func encode(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("encoding value: %w", err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,201 @@
+package client
+
+import (
+	"bufio"
+	"errors"
+	distributed_cache "github.com/diogenes-moreira/distributed-cache"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeServer is a server of a single cache that speaks the subset of RESP of dcache-server
+type fakeServer struct {
+	mutex    sync.Mutex
+	values   map[string]string
+	expires  []string // TTLs of PEXPIRE
+	conns    atomic.Int32
+	drop     atomic.Int32 // number of connections closed before the reply
+	listener net.Listener
+}
+
+func startFakeServer(t *testing.T) *fakeServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	s := &fakeServer{values: make(map[string]string), listener: listener}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.conns.Add(1)
+			go s.handle(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeServer) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		args := make([]string, n)
+		for i := range args {
+			reader.ReadString('\n')
+			arg, _ := reader.ReadString('\n')
+			args[i] = strings.TrimSuffix(arg, "\r\n")
+		}
+		if s.drop.Load() > 0 {
+			s.drop.Add(-1)
+			return
+		}
+		s.mutex.Lock()
+		switch args[0] {
+		case "GET":
+			if value, ok := s.values[args[1]]; ok {
+				writer.WriteString("$" + strconv.Itoa(len(value)) + "\r\n" + value + "\r\n")
+			} else {
+				writer.WriteString("$-1\r\n")
+			}
+		case "SET":
+			s.values[args[1]] = args[2]
+			writer.WriteString("+OK\r\n")
+		case "DEL":
+			deleted := 0
+			for _, key := range args[1:] {
+				if _, ok := s.values[key]; ok {
+					deleted++
+					delete(s.values, key)
+				}
+			}
+			writer.WriteString(":" + strconv.Itoa(deleted) + "\r\n")
+		case "PEXPIRE":
+			s.expires = append(s.expires, args[2])
+			if _, ok := s.values[args[1]]; ok {
+				writer.WriteString(":1\r\n")
+			} else {
+				writer.WriteString(":0\r\n")
+			}
+		case "FLUSHDB":
+			s.values = make(map[string]string)
+			writer.WriteString("+OK\r\n")
+		case "SELECT":
+			if args[1] == "sessions" {
+				writer.WriteString("+OK\r\n")
+			} else {
+				writer.WriteString("-ERR unknown cache '" + args[1] + "'\r\n")
+			}
+		default:
+			writer.WriteString("-ERR unknown command '" + args[0] + "'\r\n")
+		}
+		s.mutex.Unlock()
+		if reader.Buffered() == 0 {
+			writer.Flush()
+		}
+	}
+}
+
+func TestClient_Commands(t *testing.T) {
+	server := startFakeServer(t)
+	c, err := New(server.listener.Addr().String(), WithDatabase("sessions"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer c.Close()
+
+	if err := c.Set("key", "value"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if val := c.Get("key"); val != "value" {
+		t.Errorf("Expected value, got %v", val)
+	}
+	c.Set("numbers", []int{1, 2})
+	if val := c.Get("numbers"); val != "[1,2]" {
+		t.Errorf("Expected the JSON of the value, got %v", val)
+	}
+	if exists, err := c.Expire("key", time.Minute); !exists || err != nil {
+		t.Errorf("Expected key to exist, got %v and %v", exists, err)
+	}
+	c.Expire("key", 500*time.Microsecond)
+	server.mutex.Lock()
+	if len(server.expires) != 2 || server.expires[0] != "60000" || server.expires[1] != "1" {
+		t.Errorf("Expected the TTLs rounded up to milliseconds, got %v", server.expires)
+	}
+	server.mutex.Unlock()
+	if err := c.Delete("key"); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if val := c.Get("key"); val != nil {
+		t.Errorf("Expected nil, got %v", val)
+	}
+	c.Clean()
+	if val := c.Get("numbers"); val != nil {
+		t.Errorf("Expected nil after clean, got %v", val)
+	}
+}
+
+func TestClient_Pipelining(t *testing.T) {
+	server := startFakeServer(t)
+	c, _ := New(server.listener.Addr().String())
+	defer c.Close()
+
+	if err := c.SetMany(map[string]interface{}{"key1": "value1", "key2": "value2", "key3": "value3"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := c.DeleteMany([]string{"key3"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	values := c.GetMany([]string{"key1", "key2", "key3"})
+	if len(values) != 2 || values["key1"] != "value1" || values["key2"] != "value2" {
+		t.Errorf("Expected key1 and key2, got %v", values)
+	}
+	if conns := server.conns.Load(); conns != 1 {
+		t.Errorf("Expected a single pooled connection, got %d", conns)
+	}
+}
+
+func TestClient_Retries(t *testing.T) {
+	server := startFakeServer(t)
+	c, _ := New(server.listener.Addr().String(), WithRetries(1))
+	defer c.Close()
+
+	server.drop.Store(1)
+	if err := c.Set("key", "value"); err != nil {
+		t.Errorf("Expected the retry to succeed, got %v", err)
+	}
+	server.drop.Store(2)
+	if err := c.Set("key", "value"); err == nil {
+		t.Errorf("Expected an error after the retries")
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	server := startFakeServer(t)
+	c, _ := New(server.listener.Addr().String(), WithDatabase("unknown"), WithRetries(0))
+	if err := c.Set("key", "value"); err == nil || !strings.Contains(err.Error(), "unknown cache") {
+		t.Errorf("Expected the error of SELECT, got %v", err)
+	}
+	c.Close()
+	if err := c.Set("key", "value"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+
+	var configErr *distributed_cache.ConfigError
+	if _, err := New("localhost:6379", WithPoolSize(0)); !errors.As(err, &configErr) {
+		t.Errorf("Expected a ConfigError, got %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package client
+
+// In this file, you can find the encoding of the commands sent to the server
+// and the decoding of its replies, the subset of RESP spoken by dcache-server.
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ServerError is an error replied by the server, the connection is still usable
+type ServerError string
+
+func (e ServerError) Error() string {
+	return string(e)
+}
+
+// errProtocol is returned when the server does not reply with RESP
+var errProtocol = errors.New("protocol error")
+
+// writeCommand writes a command as an array of bulk strings
+func writeCommand(w *bufio.Writer, args ...string) {
+	w.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		w.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n" + arg + "\r\n")
+	}
+}
+
+// result is a reply of the server, value is nil for the null bulk string,
+// a string, an int64 or a []result
+type result struct {
+	value interface{}
+	err   error // ServerError
+}
+
+// readReply reads a reply of the server
+func readReply(r *bufio.Reader) (result, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return result{}, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return result{}, fmt.Errorf("%w: empty reply", errProtocol)
+	}
+	switch line[0] {
+	case '+':
+		return result{value: line[1:]}, nil
+	case '-':
+		return result{err: ServerError(line[1:])}, nil
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			return result{}, fmt.Errorf("%w: invalid integer %q", errProtocol, line)
+		}
+		return result{value: n}, nil
+	case '$':
+		length, err := strconv.Atoi(line[1:])
+		if err != nil || length < -1 {
+			return result{}, fmt.Errorf("%w: invalid bulk length %q", errProtocol, line)
+		}
+		if length == -1 {
+			return result{}, nil
+		}
+		data := make([]byte, length+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return result{}, err
+		}
+		return result{value: string(data[:length])}, nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < -1 {
+			return result{}, fmt.Errorf("%w: invalid array length %q", errProtocol, line)
+		}
+		items := make([]result, max(n, 0))
+		for i := range items {
+			if items[i], err = readReply(r); err != nil {
+				return result{}, err
+			}
+		}
+		return result{value: items}, nil
+	default:
+		return result{}, fmt.Errorf("%w: unexpected reply %q", errProtocol, line)
+	}
+}
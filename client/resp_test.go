@@ -0,0 +1,36 @@
+package client
+
+import (
+	"bufio"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadReply(t *testing.T) {
+	tests := map[string]interface{}{
+		"+OK\r\n":                      "OK",
+		":3\r\n":                       int64(3),
+		"$5\r\nva\r\nl\r\n":            "va\r\nl",
+		"$-1\r\n":                      nil,
+		"*2\r\n$1\r\na\r\n:1\r\n":      []result{{value: "a"}, {value: int64(1)}},
+		"-ERR unknown command 'X'\r\n": ServerError("ERR unknown command 'X'"),
+	}
+	for input, expected := range tests {
+		reply, err := readReply(bufio.NewReader(strings.NewReader(input)))
+		if err != nil {
+			t.Fatalf("Expected no error for %q, got %v", input, err)
+		}
+		got := reply.value
+		if reply.err != nil {
+			got = reply.err
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %#v for %q, got %#v", expected, input, got)
+		}
+	}
+	if _, err := readReply(bufio.NewReader(strings.NewReader("?\r\n"))); !errors.Is(err, errProtocol) {
+		t.Errorf("Expected a protocol error, got %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+// Command dcache-server hosts named caches for the clients that are not
+// written in Go. It speaks a subset of RESP, the protocol of Redis, over TCP:
+// PING, GET, SET with EX or PX, DEL, EXPIRE, PEXPIRE, INFO, FLUSHDB, FLUSHALL,
+// SELECT and QUIT. Each cache is an LRUCacheWithTTL that replicates its keys with the
+// other nodes of the cache, Go nodes included.
+//
+// Usage:
+//
+//	dcache-server -config server.yaml
+//	dcache-server -listen :6379 -name sessions -port 12345 -ttl 10m
+//
+// The configuration file has the address of the server and the caches,
+// with the settings of distributed_cache.Config:
+//
+//	listen: ":6379"
+//	caches:
+//	  - name: sessions
+//	    port: 12345
+//	    max_entries: 10000
+//	    ttl: 10m
+//
+// SELECT takes the index of a cache in the file or its name.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	distributed_cache "github.com/diogenes-moreira/distributed-cache"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// defaultTTL is the TTL of the caches that do not set one
+const defaultTTL = time.Hour
+
+// serverConfig are the settings of the server
+type serverConfig struct {
+	Listen string                     `json:"listen" yaml:"listen"`
+	Caches []distributed_cache.Config `json:"caches" yaml:"caches"`
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	if err := run(ctx, os.Args[1:], logger); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		fmt.Fprintln(os.Stderr, "dcache-server:", err)
+		os.Exit(1)
+	}
+}
+
+// run starts the caches and serves the clients until the context is done
+func run(ctx context.Context, args []string, logger *slog.Logger) error {
+	config, err := parse(args)
+	if err != nil {
+		return err
+	}
+	listener, err := net.Listen("tcp", config.Listen)
+	if err != nil {
+		return err
+	}
+	s, err := newServer(config, logger)
+	if err != nil {
+		listener.Close()
+		return err
+	}
+	defer s.close()
+	logger.Info("serving", "address", listener.Addr().String(), "caches", strings.Join(s.names, ","))
+	return s.serve(ctx, listener)
+}
+
+// parse returns the settings of the server from the configuration file or the flags
+func parse(args []string) (serverConfig, error) {
+	var config serverConfig
+	var path string
+	var cache distributed_cache.Config
+	var ttl time.Duration
+	flags := flag.NewFlagSet("dcache-server", flag.ContinueOnError)
+	flags.StringVar(&path, "config", "", "JSON or YAML file with the settings of the server")
+	flags.StringVar(&config.Listen, "listen", ":6379", "TCP address of the server")
+	flags.StringVar(&cache.Name, "name", "", "name of the cache, when there is no configuration file")
+	flags.IntVar(&cache.Port, "port", 0, "UDP port of the cache")
+	flags.StringVar(&cache.Broadcast, "broadcast", "", "broadcast address, "+distributed_cache.DefaultBroadcast+" by default")
+	flags.IntVar(&cache.MaxEntries, "max-entries", 0, "maximum number of entries of the cache, unbounded when 0")
+	flags.DurationVar(&ttl, "ttl", defaultTTL, "time-to-live of the entries of the cache")
+	flags.StringVar(&cache.SecurityKey, "key", "", "shared key to sign the datagrams")
+	if err := flags.Parse(args); err != nil {
+		return config, err
+	}
+	if flags.NArg() > 0 {
+		return config, fmt.Errorf("unexpected argument %q", flags.Arg(0))
+	}
+	if path == "" {
+		cache.TTL = distributed_cache.Duration(ttl)
+		config.Caches = []distributed_cache.Config{cache}
+		return config, nil
+	}
+	listen := config.Listen
+	if err := distributed_cache.LoadFile(path, &config); err != nil {
+		return config, err
+	}
+	if config.Listen == "" {
+		config.Listen = listen
+	}
+	return config, nil
+}
+
+// newServer creates the caches of the server
+func newServer(config serverConfig, logger *slog.Logger) (*server, error) {
+	if len(config.Caches) == 0 {
+		return nil, errors.New("no caches, use -config or -name and -port")
+	}
+	s := &server{logger: logger}
+	for _, cacheConfig := range config.Caches {
+		if cacheConfig.TTL <= 0 {
+			cacheConfig.TTL = distributed_cache.Duration(defaultTTL)
+		}
+		cache, err := distributed_cache.NewFromConfig(cacheConfig, distributed_cache.WithLogger(logger))
+		if err != nil {
+			s.close()
+			return nil, fmt.Errorf("cache %q: %w", cacheConfig.Name, err)
+		}
+		s.caches = append(s.caches, cache)
+		s.names = append(s.names, cacheConfig.Name)
+	}
+	return s, nil
+}
+
+// close stops the caches, the pending messages are sent
+func (s *server) close() {
+	for _, cache := range s.caches {
+		cache.Close()
+	}
+}
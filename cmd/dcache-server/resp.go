@@ -0,0 +1,134 @@
+package main
+
+// In this file, you can find the subset of RESP, the protocol of Redis, spoken
+// by the server: the commands are arrays of bulk strings, or inline commands
+// separated by spaces, and the replies are simple strings, errors, integers,
+// bulk strings and arrays.
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxBulkLength is the maximum size of an argument of a command
+const maxBulkLength = 1 << 20
+
+// maxArguments is the maximum number of arguments of a command
+const maxArguments = 1024
+
+// errProtocol is returned when a client does not speak RESP,
+// the connection is closed after the error is replied
+var errProtocol = errors.New("protocol error")
+
+// readCommand reads a command and its arguments
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 0 || n > maxArguments {
+		return nil, fmt.Errorf("%w: invalid multibulk length", errProtocol)
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "$") {
+			return nil, fmt.Errorf("%w: expected '$', got %q", errProtocol, line)
+		}
+		length, err := strconv.Atoi(line[1:])
+		if err != nil || length < 0 || length > maxBulkLength {
+			return nil, fmt.Errorf("%w: invalid bulk length", errProtocol)
+		}
+		data := make([]byte, length+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		if string(data[length:]) != "\r\n" {
+			return nil, fmt.Errorf("%w: bulk string without CRLF", errProtocol)
+		}
+		args[i] = string(data[:length])
+	}
+	return args, nil
+}
+
+// readLine reads a line ended with CRLF or LF
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		if errors.Is(err, io.EOF) && line != "" {
+			return "", io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	if len(line) > maxBulkLength {
+		return "", fmt.Errorf("%w: line too long", errProtocol)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// reply is a RESP reply
+type reply interface {
+	write(w *bufio.Writer)
+}
+
+type simpleString string
+
+func (s simpleString) write(w *bufio.Writer) {
+	w.WriteString("+" + string(s) + "\r\n")
+}
+
+type errorReply string
+
+func (e errorReply) write(w *bufio.Writer) {
+	w.WriteString("-" + string(e) + "\r\n")
+}
+
+type integer int64
+
+func (i integer) write(w *bufio.Writer) {
+	w.WriteString(":" + strconv.FormatInt(int64(i), 10) + "\r\n")
+}
+
+// bulkString is a string or nil, the null bulk string
+type bulkString struct {
+	value string
+	null  bool
+}
+
+func (b bulkString) write(w *bufio.Writer) {
+	if b.null {
+		w.WriteString("$-1\r\n")
+		return
+	}
+	w.WriteString("$" + strconv.Itoa(len(b.value)) + "\r\n" + b.value + "\r\n")
+}
+
+type array []reply
+
+func (a array) write(w *bufio.Writer) {
+	w.WriteString("*" + strconv.Itoa(len(a)) + "\r\n")
+	for _, item := range a {
+		item.write(w)
+	}
+}
+
+var (
+	ok   = simpleString("OK")
+	null = bulkString{null: true}
+)
+
+// errorf returns an error reply with the ERR prefix
+func errorf(format string, args ...interface{}) errorReply {
+	return errorReply("ERR " + fmt.Sprintf(format, args...))
+}
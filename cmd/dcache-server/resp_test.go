@@ -0,0 +1,26 @@
+package main
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestReadCommand(t *testing.T) {
+	tests := map[string]string{
+		"*2\r\n$3\r\nGET\r\n$3\r\nkey\r\n": "GET key",
+		"SET key  value\n":                 "SET key value",
+		"*1\r\n$-5\r\n":                    "protocol error",
+		"*1\r\n+GET\r\n":                   "protocol error",
+		"*1\r\n$3\r\nGETXX":                "protocol error",
+	}
+	for input, expected := range tests {
+		args, err := readCommand(bufio.NewReader(strings.NewReader(input)))
+		if err != nil {
+			args = []string{err.Error()}
+		}
+		if !strings.HasPrefix(strings.Join(args, " "), expected) {
+			t.Errorf("Expected %q for %q, got %q", expected, input, args)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+// In this file, you can find the server, that runs the commands of the
+// clients on the named caches. Each connection starts in the first cache,
+// SELECT changes it by index or by name.
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	distributed_cache "github.com/diogenes-moreira/distributed-cache"
+	"github.com/diogenes-moreira/distributed-cache/internal/format"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// server hosts the named caches
+type server struct {
+	caches []*distributed_cache.Cache
+	names  []string
+	logger *slog.Logger
+	wg     sync.WaitGroup
+}
+
+// serve accepts connections until the context is done
+func (s *server) serve(ctx context.Context, listener net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				s.wg.Wait()
+				return nil
+			}
+			return err
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(ctx, conn)
+		}()
+	}
+}
+
+// handle runs the commands of a connection until it is closed
+func (s *server) handle(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+	session := &session{}
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			if errors.Is(err, errProtocol) {
+				errorf("%v", err).write(writer)
+				writer.Flush()
+			} else if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+				s.logger.Debug("reading command failed", "client", conn.RemoteAddr().String(), "error", err)
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		s.execute(session, args).write(writer)
+		if session.quit {
+			writer.Flush()
+			return
+		}
+		// the replies of pipelined commands are sent together
+		if reader.Buffered() == 0 {
+			if err := writer.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// session is the state of a connection
+type session struct {
+	db   int // index of the selected cache
+	quit bool
+}
+
+// execute runs a command on the selected cache
+func (s *server) execute(session *session, args []string) reply {
+	cache := s.caches[session.db]
+	name := strings.ToUpper(args[0])
+	switch {
+	case name == "PING" && len(args) <= 2:
+		if len(args) == 2 {
+			return bulkString{value: args[1]}
+		}
+		return simpleString("PONG")
+	case name == "QUIT" && len(args) == 1:
+		session.quit = true
+		return ok
+	case name == "SELECT" && len(args) == 2:
+		return s.selectCache(session, args[1])
+	case name == "COMMAND":
+		// redis-cli asks for the documentation of the commands when it connects
+		return array{}
+	case name == "GET" && len(args) == 2:
+		value := cache.Get(args[1])
+		if value == nil {
+			return null
+		}
+		return bulkString{value: format.Value(value)}
+	case name == "SET" && len(args) >= 3:
+		return set(cache, args[1], args[2], args[3:])
+	case name == "DEL" && len(args) >= 2:
+		deleted := 0
+		for _, key := range args[1:] {
+			existed, err := cache.Remove(key)
+			if err != nil {
+				return errorf("%v", err)
+			}
+			if existed {
+				deleted++
+			}
+		}
+		return integer(deleted)
+	case (name == "EXPIRE" || name == "PEXPIRE") && len(args) == 3:
+		n, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			return errorf("value is not an integer or out of range")
+		}
+		unit := time.Second
+		if name == "PEXPIRE" {
+			unit = time.Millisecond
+		}
+		exists, err := cache.Expire(args[1], time.Duration(n)*unit)
+		if err != nil {
+			return errorf("%v", err)
+		}
+		if exists {
+			return integer(1)
+		}
+		return integer(0)
+	case name == "INFO" && len(args) <= 2:
+		return bulkString{value: info(cache.Stats())}
+	case (name == "FLUSHDB" || name == "FLUSHALL") && len(args) <= 2:
+		if name == "FLUSHALL" {
+			for _, c := range s.caches {
+				c.Clean()
+			}
+		} else {
+			cache.Clean()
+		}
+		return ok
+	case isCommand(name):
+		return errorf("wrong number of arguments for '%s' command", strings.ToLower(args[0]))
+	default:
+		return errorf("unknown command '%s'", args[0])
+	}
+}
+
+// isCommand returns true for the commands of the server
+func isCommand(name string) bool {
+	switch name {
+	case "PING", "QUIT", "SELECT", "GET", "SET", "DEL", "EXPIRE", "PEXPIRE", "INFO", "FLUSHDB", "FLUSHALL":
+		return true
+	}
+	return false
+}
+
+// selectCache selects a cache by index or by name
+func (s *server) selectCache(session *session, db string) reply {
+	if index, err := strconv.Atoi(db); err == nil {
+		if index < 0 || index >= len(s.caches) {
+			return errorf("DB index is out of range")
+		}
+		session.db = index
+		return ok
+	}
+	for i, name := range s.names {
+		if name == db {
+			session.db = i
+			return ok
+		}
+	}
+	return errorf("unknown cache '%s'", db)
+}
+
+// set runs SET key value [EX seconds | PX milliseconds]
+func set(cache *distributed_cache.Cache, key, value string, options []string) reply {
+	var ttl time.Duration
+	if len(options) > 0 {
+		if len(options) != 2 {
+			return errorf("syntax error")
+		}
+		n, err := strconv.ParseInt(options[1], 10, 64)
+		if err != nil || n <= 0 {
+			return errorf("invalid expire time in 'set' command")
+		}
+		switch strings.ToUpper(options[0]) {
+		case "EX":
+			ttl = time.Duration(n) * time.Second
+		case "PX":
+			ttl = time.Duration(n) * time.Millisecond
+		default:
+			return errorf("syntax error")
+		}
+	}
+	var err error
+	if ttl > 0 {
+		err = cache.SetWithTTL(key, value, ttl)
+	} else {
+		err = cache.Set(key, value)
+	}
+	if err != nil {
+		return errorf("%v", err)
+	}
+	return ok
+}
+
+// info formats the stats of a cache like the INFO reply of Redis,
+// a line with the name and the value of each field
+func info(stats distributed_cache.Stats) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Stats\r\nkeyspace_hits:%d\r\nkeyspace_misses:%d\r\n", stats.Hits, stats.Misses)
+	fmt.Fprintf(&b, "fills:%d\r\nfill_errors:%d\r\n", stats.Fills, stats.FillErrors)
+	fmt.Fprintf(&b, "evicted_keys:%d\r\nexpired_keys:%d\r\n", stats.Evictions.Capacity, stats.Evictions.Expired)
+	fmt.Fprintf(&b, "# Keyspace\r\nkeys:%d\r\nused_memory:%d\r\n", stats.Entries, stats.Bytes)
+	return b.String()
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	distributed_cache "github.com/diogenes-moreira/distributed-cache"
+	"github.com/diogenes-moreira/distributed-cache/cachetest"
+	"github.com/diogenes-moreira/distributed-cache/client"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+)
+
+func startServer(t *testing.T, caches ...distributed_cache.Config) net.Addr {
+	s, err := newServer(serverConfig{Caches: caches}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.serve(ctx, listener)
+		s.close()
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return listener.Addr()
+}
+
+func TestServer_Commands(t *testing.T) {
+	addr := startServer(t, distributed_cache.Config{Name: "sessions", Port: 12404},
+		distributed_cache.Config{Name: "carts", Port: 12405})
+	conn, err := net.Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	tests := []struct {
+		command  string
+		expected string
+	}{
+		{"*1\r\n$4\r\nPING\r\n", "+PONG\r\n"},
+		{"*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n", "+OK\r\n"},
+		{"GET key\r\n", "$5\r\nvalue\r\n"},
+		{"EXPIRE key 100\r\n", ":1\r\n"},
+		{"EXPIRE missing 100\r\n", ":0\r\n"},
+		{"PEXPIRE key 100000\r\n", ":1\r\n"},
+		{"SELECT carts\r\n", "+OK\r\n"},
+		{"GET key\r\n", "$-1\r\n"},
+		{"SET key other PX 50\r\n", "+OK\r\n"},
+		{"SELECT 0\r\n", "+OK\r\n"},
+		{"DEL key missing\r\n", ":1\r\n"},
+		{"GET key\r\n", "$-1\r\n"},
+		{"SET key value NX\r\n", "-ERR syntax error\r\n"},
+		{"GET\r\n", "-ERR wrong number of arguments for 'get' command\r\n"},
+		{"HGET key field\r\n", "-ERR unknown command 'HGET'\r\n"},
+		{"SELECT 2\r\n", "-ERR DB index is out of range\r\n"},
+	}
+	for _, test := range tests {
+		if _, err := conn.Write([]byte(test.command)); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		response := make([]byte, len(test.expected))
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		if _, err := io.ReadFull(reader, response); err != nil || string(response) != test.expected {
+			t.Errorf("Expected %q for %q, got %q (%v)", test.expected, test.command, response, err)
+		}
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	conn.Write([]byte("SELECT carts\r\nGET key\r\nFLUSHALL\r\nQUIT\r\n"))
+	response, _ := io.ReadAll(reader)
+	if string(response) != "+OK\r\n$-1\r\n+OK\r\n+OK\r\n" {
+		t.Errorf("Expected the key of carts to be expired, got %q", response)
+	}
+}
+
+func TestServer_ClientConformance(t *testing.T) {
+	port := 12520
+	cachetest.Run(t, func(t *testing.T) distributed_cache.DistributedCache {
+		addr := startServer(t, distributed_cache.Config{Name: "conformance", Port: port})
+		port++
+		c, err := client.New(addr.String())
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return c
+	})
+}
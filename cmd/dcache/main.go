@@ -0,0 +1,250 @@
+// Command dcache joins a cache cluster to read, write and watch its keys.
+// It uses the same wire protocol as the library, as an observer node that
+// is never a member of the cluster, so it must run on a host where no node
+// of the cache listens on the same port.
+//
+// Usage:
+//
+//	dcache [flags] get KEY
+//	dcache [flags] set KEY VALUE
+//	dcache [flags] del KEY
+//	dcache [flags] clean
+//	dcache [flags] watch
+//	dcache [flags] members
+//	dcache [flags] stats URL
+//
+// members lists only the nodes of partitioned caches, the other caches send
+// no heartbeats. An observer has no statistics of its own, stats asks a member
+// for them at the URL where its AdminHandler is mounted, with the
+// -authorization header when it is set.
+//
+// The settings are read from the -config file, then from the environment
+// variables with the -env prefix (DCACHE_NAME, DCACHE_PORT...) and then from
+// the flags.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	distributed_cache "github.com/diogenes-moreira/distributed-cache"
+	"github.com/diogenes-moreira/distributed-cache/internal/format"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errUsage is returned when the command line is not valid
+var errUsage = errors.New("usage: dcache [flags] get KEY | set KEY VALUE | del KEY | clean | watch | members | stats URL")
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := run(ctx, os.Args[1:], os.Stdout); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		fmt.Fprintln(os.Stderr, "dcache:", err)
+		if errors.Is(err, errUsage) {
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
+
+// options are the flags that are not settings of the cache
+type options struct {
+	wait          time.Duration
+	heartbeats    bool
+	authorization string
+}
+
+// run runs a command with its flags, the output is written to out
+func run(ctx context.Context, args []string, out io.Writer) error {
+	config, opts, command, err := parse(args)
+	if err != nil {
+		return err
+	}
+	if command[0] == "stats" {
+		return stats(ctx, command[1], opts.authorization, out)
+	}
+	var watch chan distributed_cache.Event
+	cacheOptions := []distributed_cache.Option{distributed_cache.WithObserver(),
+		distributed_cache.WithForwardTimeout(opts.wait)}
+	if command[0] == "watch" {
+		watch = make(chan distributed_cache.Event, 1024)
+		cacheOptions = append(cacheOptions, distributed_cache.WithMessageHook(func(event distributed_cache.Event) {
+			select {
+			case watch <- event:
+			default:
+			}
+		}))
+	}
+	cache, err := distributed_cache.NewFromConfig(config, cacheOptions...)
+	if err != nil {
+		return err
+	}
+	defer cache.Close()
+
+	switch command[0] {
+	case "get":
+		value, ok := cache.GetRemote(command[1])
+		if !ok {
+			return fmt.Errorf("key %q not found", command[1])
+		}
+		fmt.Fprintln(out, format.Value(value))
+	case "set":
+		return cache.Set(command[1], command[2])
+	case "del":
+		return cache.Delete(command[1])
+	case "clean":
+		cache.Clean()
+	case "watch":
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case event := <-watch:
+				if event.Op == "heartbeat" && !opts.heartbeats {
+					continue
+				}
+				fmt.Fprintln(out, formatEvent(event))
+			}
+		}
+	case "members":
+		sleep(ctx, opts.wait)
+		for _, member := range cache.Members() {
+			if !member.Local {
+				fmt.Fprintf(out, "%s\t%s\t%s\n", member.Node, member.Address, member.LastSeen.Format(time.RFC3339))
+			}
+		}
+	}
+	return nil
+}
+
+// stats writes the statistics of a member, read from its AdminHandler at url
+func stats(ctx context.Context, url, authorization string, out io.Writer) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(url, "/")+"/stats", nil)
+	if err != nil {
+		return err
+	}
+	if authorization != "" {
+		request.Header.Set("Authorization", authorization)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", response.Status, strings.TrimSpace(string(body)))
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, body, "", "  "); err != nil {
+		return err
+	}
+	_, err = indented.WriteTo(out)
+	return err
+}
+
+// parse returns the settings of the cache, the options and the command with its arguments
+func parse(args []string) (distributed_cache.Config, options, []string, error) {
+	var opts options
+	var configPath, prefix string
+	flags := flag.NewFlagSet("dcache", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), errUsage)
+		flags.PrintDefaults()
+	}
+	flags.StringVar(&configPath, "config", "", "JSON or YAML file with the settings of the cache")
+	flags.StringVar(&prefix, "env", "DCACHE", "prefix of the environment variables with the settings")
+	name := flags.String("name", "", "name of the cache")
+	port := flags.Int("port", 0, "UDP port of the cache")
+	broadcast := flags.String("broadcast", "", "broadcast address, "+distributed_cache.DefaultBroadcast+" by default")
+	codec := flags.String("codec", "", "codec of the values: gob, json or msgpack")
+	compression := flags.String("compression", "", "compression of the values: none, gzip or flate")
+	key := flags.String("key", "", "shared key to sign the datagrams")
+	flags.DurationVar(&opts.wait, "wait", 2*time.Second, "time to wait for the answers to get and the heartbeats of the members")
+	flags.BoolVar(&opts.heartbeats, "heartbeats", false, "show the heartbeats in watch")
+	flags.StringVar(&opts.authorization, "authorization", "", "Authorization header sent to the AdminHandler in stats")
+	if err := flags.Parse(args); err != nil {
+		return distributed_cache.Config{}, opts, nil, err
+	}
+
+	var config distributed_cache.Config
+	var err error
+	if configPath != "" {
+		if config, err = distributed_cache.LoadConfig(configPath); err != nil {
+			return config, opts, nil, err
+		}
+	}
+	if err = config.LoadEnv(prefix); err != nil {
+		return config, opts, nil, err
+	}
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "name":
+			config.Name = *name
+		case "port":
+			config.Port = *port
+		case "broadcast":
+			config.Broadcast = *broadcast
+		case "codec":
+			config.Codec = *codec
+		case "compression":
+			config.Compression = *compression
+		case "key":
+			config.SecurityKey = *key
+		}
+	})
+
+	command := flags.Args()
+	arguments := map[string]int{"get": 1, "set": 2, "del": 1, "clean": 0, "watch": 0, "members": 0, "stats": 1}
+	if len(command) == 0 {
+		return config, opts, nil, errUsage
+	}
+	if n, ok := arguments[command[0]]; !ok || len(command) != n+1 {
+		return config, opts, nil, errUsage
+	}
+	if command[0] == "stats" {
+		// stats does not join the cluster
+		return config, opts, command, nil
+	}
+	return config, opts, command, config.Validate()
+}
+
+// formatEvent formats a message received from a node as a line
+func formatEvent(event distributed_cache.Event) string {
+	line := time.Now().Format("15:04:05.000") + "\t" + event.Node.String() + "\t"
+	switch {
+	case event.Clean:
+		return line + "clean"
+	case event.Op == "write" && event.Value == nil:
+		return line + "del\t" + strconv.Quote(event.Key)
+	case event.Op == "write":
+		return line + "set\t" + strconv.Quote(event.Key) + "\t" + format.Value(event.Value)
+	case event.Key == "":
+		return line + event.Op
+	default:
+		return line + event.Op + "\t" + strconv.Quote(event.Key)
+	}
+}
+
+// sleep waits for d or until the context is done
+func sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	distributed_cache "github.com/diogenes-moreira/distributed-cache"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	t.Setenv("DCACHE_PORT", "12345")
+	t.Setenv("DCACHE_NAME", "envCache")
+	config, _, command, err := parse([]string{"-name", "flagCache", "-codec", "json", "set", "key", "value"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if config.Name != "flagCache" || config.Port != 12345 || config.Codec != "json" {
+		t.Errorf("Expected the flags over the environment, got %+v", config)
+	}
+	if strings.Join(command, " ") != "set key value" {
+		t.Errorf("Expected set key value, got %v", command)
+	}
+
+	for _, args := range [][]string{{}, {"get"}, {"set", "key"}, {"unknown"}} {
+		if _, _, _, err := parse(args); !errors.Is(err, errUsage) {
+			t.Errorf("Expected a usage error for %v, got %v", args, err)
+		}
+	}
+	var configErr *distributed_cache.ConfigError
+	if _, _, _, err := parse([]string{"-port", "0", "clean"}); !errors.As(err, &configErr) {
+		t.Errorf("Expected a ConfigError, got %v", err)
+	}
+}
+
+func TestFormatEvent(t *testing.T) {
+	tests := map[string]distributed_cache.Event{
+		"set\t\"key\"\tvalue": {Op: "write", Key: "key", Value: "value"},
+		"set\t\"key\"\t[1,2]": {Op: "write", Key: "key", Value: []int{1, 2}},
+		"del\t\"key\"":        {Op: "write", Key: "key"},
+		"clean":               {Op: "write", Key: "<sendClean>", Clean: true},
+		"heartbeat":           {Op: "heartbeat"},
+		"invalidate\t\"key\"": {Op: "invalidate", Key: "key"},
+	}
+	for expected, event := range tests {
+		if line := formatEvent(event); !strings.HasSuffix(line, "\t"+expected) {
+			t.Errorf("Expected %q, got %q", expected, line)
+		}
+	}
+}
+
+func TestRun_Stats(t *testing.T) {
+	cache := distributed_cache.NewCache("statsCache", "255.255.255.255", ":12529")
+	defer cache.StopListener()
+	cache.Set("key", "value")
+	cache.Get("key")
+	auth := func(r *http.Request) error {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			return errors.New("wrong token")
+		}
+		return nil
+	}
+	server := httptest.NewServer(http.StripPrefix("/admin", distributed_cache.AdminHandler(cache, auth)))
+	defer server.Close()
+
+	var out bytes.Buffer
+	if err := run(context.Background(), []string{"-authorization", "Bearer secret", "stats", server.URL + "/admin/"}, &out); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var stats distributed_cache.Stats
+	if err := json.Unmarshal(out.Bytes(), &stats); err != nil {
+		t.Fatalf("Expected the stats as JSON, got %q: %v", out.String(), err)
+	}
+	if stats.Hits != 1 {
+		t.Errorf("Expected the stats of the member, got %+v", stats)
+	}
+	if err := run(context.Background(), []string{"stats", server.URL + "/admin"}, &out); err == nil {
+		t.Errorf("Expected an error without the authorization")
+	}
+}
@@ -0,0 +1,263 @@
+package distributed_cache
+
+// In this file, you can find the Codec interface and the built-in codecs
+// used to encode the values sent between nodes.
+// Every cache can select its own codec, the codec ID travels in the
+// envelope so a node that receives a value encoded with a different codec
+// reports a clear error instead of failing silently.
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/vmihailenco/msgpack/v5"
+	"reflect"
+)
+
+// Codec encodes and decodes the values stored in the cache
+// to send them to the other nodes
+type Codec interface {
+	ID() uint8                                  // ID is sent in the envelope to identify the codec
+	Name() string                               // Name is a human-readable name used in errors
+	Marshal(value interface{}) ([]byte, error)  // Marshal encodes a value
+	Unmarshal(data []byte) (interface{}, error) // Unmarshal decodes a value
+}
+
+// Codec IDs of the built-in codecs
+const (
+	CodecIDGob      uint8 = 1
+	CodecIDJSON     uint8 = 2
+	CodecIDMsgPack  uint8 = 3
+	CodecIDProtobuf uint8 = 4
+)
+
+// ErrCodecMismatch is returned when a message was encoded with a codec
+// different from the one configured in the cache
+var ErrCodecMismatch = errors.New("codec mismatch")
+
+// ErrUnknownCodec is returned when a message was encoded with a codec
+// that is not known by this node
+var ErrUnknownCodec = errors.New("unknown codec")
+
+// defaultCodec is used when a cache does not set a Codec
+var defaultCodec Codec = GobCodec{}
+
+// builtinCodecs are used to decode messages when no codec is configured
+var builtinCodecs = map[uint8]Codec{
+	CodecIDGob:     GobCodec{},
+	CodecIDJSON:    JSONCodec{},
+	CodecIDMsgPack: MsgPackCodec{},
+}
+
+// codecName returns the name of a codec ID for error messages
+func codecName(id uint8) string {
+	if codec, ok := builtinCodecs[id]; ok {
+		return codec.Name()
+	}
+	if id == CodecIDProtobuf {
+		return "protobuf"
+	}
+	return fmt.Sprintf("codec #%d", id)
+}
+
+// newValue calls the New function of a codec and checks that it returns a pointer
+func newValue(newFn func() interface{}) (interface{}, error) {
+	target := newFn()
+	if reflect.ValueOf(target).Kind() != reflect.Pointer {
+		return nil, fmt.Errorf("codec New must return a pointer, got %T", target)
+	}
+	return target, nil
+}
+
+// elem returns the value pointed by target
+func elem(target interface{}) interface{} {
+	return reflect.ValueOf(target).Elem().Interface()
+}
+
+// GobCodec encodes values with encoding/gob.
+// When New is nil values are encoded as interfaces, so every concrete type
+// must be registered with gob.Register in every node.
+// When New is set, it must return a pointer to the type stored in the cache,
+// the values are encoded as that concrete type and no registration is needed.
+type GobCodec struct {
+	New func() interface{}
+}
+
+func (g GobCodec) ID() uint8 {
+	return CodecIDGob
+}
+
+func (g GobCodec) Name() string {
+	return "gob"
+}
+
+func (g GobCodec) Marshal(value interface{}) ([]byte, error) {
+	var buffer bytes.Buffer
+	enc := gob.NewEncoder(&buffer)
+	var err error
+	if g.New == nil {
+		err = enc.Encode(&value)
+	} else {
+		err = enc.Encode(value)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func (g GobCodec) Unmarshal(data []byte) (interface{}, error) {
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	if g.New == nil {
+		var value interface{}
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		return value, nil
+	}
+	target, err := newValue(g.New)
+	if err != nil {
+		return nil, err
+	}
+	if err := dec.Decode(target); err != nil {
+		return nil, err
+	}
+	return elem(target), nil
+}
+
+// JSONCodec encodes values with encoding/json, so non-Go consumers can read them.
+// When New is nil values are decoded as the generic JSON types
+// (map[string]interface{}, []interface{}, float64, string and bool).
+// When New is set, it must return a pointer to the type stored in the cache.
+type JSONCodec struct {
+	New func() interface{}
+}
+
+func (j JSONCodec) ID() uint8 {
+	return CodecIDJSON
+}
+
+func (j JSONCodec) Name() string {
+	return "json"
+}
+
+func (j JSONCodec) Marshal(value interface{}) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+func (j JSONCodec) Unmarshal(data []byte) (interface{}, error) {
+	if j.New == nil {
+		var value interface{}
+		if err := json.Unmarshal(data, &value); err != nil {
+			return nil, err
+		}
+		return value, nil
+	}
+	target, err := newValue(j.New)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, target); err != nil {
+		return nil, err
+	}
+	return elem(target), nil
+}
+
+// MsgPackCodec encodes values with MessagePack.
+// When New is nil values are decoded as the generic MessagePack types,
+// when New is set, it must return a pointer to the type stored in the cache.
+type MsgPackCodec struct {
+	New func() interface{}
+}
+
+func (m MsgPackCodec) ID() uint8 {
+	return CodecIDMsgPack
+}
+
+func (m MsgPackCodec) Name() string {
+	return "msgpack"
+}
+
+func (m MsgPackCodec) Marshal(value interface{}) ([]byte, error) {
+	return msgpack.Marshal(value)
+}
+
+func (m MsgPackCodec) Unmarshal(data []byte) (interface{}, error) {
+	if m.New == nil {
+		var value interface{}
+		if err := msgpack.Unmarshal(data, &value); err != nil {
+			return nil, err
+		}
+		return value, nil
+	}
+	target, err := newValue(m.New)
+	if err != nil {
+		return nil, err
+	}
+	if err := msgpack.Unmarshal(data, target); err != nil {
+		return nil, err
+	}
+	return elem(target), nil
+}
+
+// ProtoMessage is implemented by the protobuf messages
+// generated with gogo/protobuf or vtprotobuf
+type ProtoMessage interface {
+	Marshal() ([]byte, error)
+	Unmarshal(data []byte) error
+}
+
+// ProtobufCodec is an adapter to store protobuf messages in the cache
+// without importing a protobuf runtime in this package.
+// New must return a new empty message, the decoded value is the message itself
+// (a pointer), as protobuf messages are always used by reference.
+// MarshalFunc and UnmarshalFunc are optional, when they are nil the messages
+// must implement ProtoMessage. To use google.golang.org/protobuf set them to
+// wrappers of proto.Marshal and proto.Unmarshal.
+type ProtobufCodec struct {
+	New           func() interface{}
+	MarshalFunc   func(value interface{}) ([]byte, error)
+	UnmarshalFunc func(data []byte, target interface{}) error
+}
+
+func (p ProtobufCodec) ID() uint8 {
+	return CodecIDProtobuf
+}
+
+func (p ProtobufCodec) Name() string {
+	return "protobuf"
+}
+
+func (p ProtobufCodec) Marshal(value interface{}) ([]byte, error) {
+	if p.MarshalFunc != nil {
+		return p.MarshalFunc(value)
+	}
+	msg, ok := value.(ProtoMessage)
+	if !ok {
+		return nil, fmt.Errorf("protobuf codec: %T does not implement ProtoMessage", value)
+	}
+	return msg.Marshal()
+}
+
+func (p ProtobufCodec) Unmarshal(data []byte) (interface{}, error) {
+	if p.New == nil {
+		return nil, errors.New("protobuf codec: New is required")
+	}
+	target := p.New()
+	if p.UnmarshalFunc != nil {
+		if err := p.UnmarshalFunc(data, target); err != nil {
+			return nil, err
+		}
+		return target, nil
+	}
+	msg, ok := target.(ProtoMessage)
+	if !ok {
+		return nil, fmt.Errorf("protobuf codec: %T does not implement ProtoMessage", target)
+	}
+	if err := msg.Unmarshal(data); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
@@ -0,0 +1,73 @@
+package distributed_cache
+
+import (
+	"reflect"
+	"testing"
+)
+
+type codecTestValue struct {
+	Name  string
+	Count int
+}
+
+// codecTestProto is a minimal ProtoMessage used to test the protobuf adapter
+type codecTestProto struct {
+	Name string
+}
+
+func (p *codecTestProto) Marshal() ([]byte, error) {
+	return []byte(p.Name), nil
+}
+
+func (p *codecTestProto) Unmarshal(data []byte) error {
+	p.Name = string(data)
+	return nil
+}
+
+func TestCodecs_RoundTrip(t *testing.T) {
+	newValue := func() interface{} { return &codecTestValue{} }
+	tests := []struct {
+		codec Codec
+		value interface{}
+	}{
+		{GobCodec{}, "value"},
+		{GobCodec{New: newValue}, codecTestValue{Name: "a", Count: 1}},
+		{JSONCodec{}, "value"},
+		{JSONCodec{New: newValue}, codecTestValue{Name: "b", Count: 2}},
+		{MsgPackCodec{}, "value"},
+		{MsgPackCodec{New: newValue}, codecTestValue{Name: "c", Count: 3}},
+		{ProtobufCodec{New: func() interface{} { return &codecTestProto{} }}, &codecTestProto{Name: "d"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.codec.Name(), func(t *testing.T) {
+			data, err := tt.codec.Marshal(tt.value)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			value, err := tt.codec.Unmarshal(data)
+			if err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if !reflect.DeepEqual(value, tt.value) {
+				t.Errorf("Unmarshal() = %#v, want %#v", value, tt.value)
+			}
+		})
+	}
+}
+
+func TestCodecs_MessageWithCustomType(t *testing.T) {
+	codec := JSONCodec{New: func() interface{} { return &codecTestValue{} }}
+	msg := &message{CacheName: "testCache", Key: "testKey", Value: codecTestValue{Name: "a"}, codec: codec}
+	data, err := msg.toUDP()
+	if err != nil {
+		t.Fatalf("ToUDP() error = %v", err)
+	}
+
+	decodedMsg := message{codec: codec}
+	if err := decodedMsg.fromUDP(data); err != nil {
+		t.Fatalf("FromUDP() error = %v", err)
+	}
+	if decodedMsg.Value != msg.Value {
+		t.Errorf("Value = %#v, want %#v", decodedMsg.Value, msg.Value)
+	}
+}
@@ -0,0 +1,131 @@
+package distributed_cache
+
+// In this file, you can find the compression of the payloads sent between nodes.
+// The compression is applied to the encoded value in the toUDP/fromUDP path,
+// the envelope carries the algorithm used so nodes with different settings,
+// or nodes that do not compress at all, can still talk to each other.
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Compression is the algorithm used to compress the payloads
+type Compression uint8
+
+const (
+	CompressionNone  Compression = iota // CompressionNone sends the payloads as they are
+	CompressionGzip                     // CompressionGzip compresses the payloads with gzip
+	CompressionFlate                    // CompressionFlate compresses the payloads with raw deflate
+)
+
+// DefaultCompressionThreshold is the minimum payload size in bytes
+// that is compressed when the cache does not set a threshold
+const DefaultCompressionThreshold = 128
+
+// maxPayloadSize is the maximum size of a decompressed payload, a datagram
+// cannot carry a payload compressed with a better ratio than 64:1
+const maxPayloadSize = 64 * datagramSize
+
+// ErrUnknownCompression is returned when a message was compressed
+// with an algorithm that is not known by this node
+var ErrUnknownCompression = errors.New("unknown compression")
+
+// ErrPayloadTooLarge is returned when a payload is bigger than
+// maxPayloadSize once decompressed
+var ErrPayloadTooLarge = errors.New("payload too large")
+
+func (c Compression) String() string {
+	switch c {
+	case CompressionNone:
+		return "none"
+	case CompressionGzip:
+		return "gzip"
+	case CompressionFlate:
+		return "flate"
+	}
+	return fmt.Sprintf("compression #%d", uint8(c))
+}
+
+// compress compresses data with the given algorithm
+func compress(algorithm Compression, data []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	var writer io.WriteCloser
+	switch algorithm {
+	case CompressionGzip:
+		writer = gzip.NewWriter(&buffer)
+	case CompressionFlate:
+		w, err := flate.NewWriter(&buffer, flate.BestSpeed)
+		if err != nil {
+			return nil, err
+		}
+		writer = w
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCompression, algorithm)
+	}
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// decompress decompresses data compressed with the given algorithm
+func decompress(algorithm Compression, data []byte) ([]byte, error) {
+	var reader io.ReadCloser
+	switch algorithm {
+	case CompressionNone:
+		return data, nil
+	case CompressionGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		reader = r
+	case CompressionFlate:
+		reader = flate.NewReader(bytes.NewReader(data))
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCompression, algorithm)
+	}
+	defer reader.Close()
+	payload, err := io.ReadAll(io.LimitReader(reader, maxPayloadSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) > maxPayloadSize {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrPayloadTooLarge, maxPayloadSize)
+	}
+	return payload, nil
+}
+
+// compressPayload compresses the payload when it is bigger than the threshold,
+// but not than maxPayloadSize, and the compressed payload is smaller than the original one.
+// It returns the payload to send and the algorithm applied.
+func compressPayload(algorithm Compression, threshold int, payload []byte) ([]byte, Compression, error) {
+	if algorithm == CompressionNone {
+		return payload, CompressionNone, nil
+	}
+	if threshold <= 0 {
+		threshold = DefaultCompressionThreshold
+	}
+	if len(payload) < threshold {
+		return payload, CompressionNone, nil
+	}
+	if len(payload) > maxPayloadSize {
+		return payload, CompressionNone, nil
+	}
+	compressed, err := compress(algorithm, payload)
+	if err != nil {
+		return nil, CompressionNone, err
+	}
+	if len(compressed) >= len(payload) {
+		return payload, CompressionNone, nil
+	}
+	return compressed, algorithm, nil
+}
@@ -0,0 +1,88 @@
+package distributed_cache
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCompression_RoundTrip(t *testing.T) {
+	data := []byte(strings.Repeat(`{"name":"value","count":1}`, 40))
+	for _, algorithm := range []Compression{CompressionGzip, CompressionFlate} {
+		t.Run(algorithm.String(), func(t *testing.T) {
+			compressed, err := compress(algorithm, data)
+			if err != nil {
+				t.Fatalf("compress() error = %v", err)
+			}
+			if len(compressed) >= len(data) {
+				t.Errorf("compressed size %d, want less than %d", len(compressed), len(data))
+			}
+			decompressed, err := decompress(algorithm, compressed)
+			if err != nil {
+				t.Fatalf("decompress() error = %v", err)
+			}
+			if !bytes.Equal(decompressed, data) {
+				t.Errorf("decompress() = %s, want %s", decompressed, data)
+			}
+		})
+	}
+}
+
+func TestCompression_LimitsDecompressedSize(t *testing.T) {
+	bomb := make([]byte, maxPayloadSize+1)
+	for _, algorithm := range []Compression{CompressionGzip, CompressionFlate} {
+		t.Run(algorithm.String(), func(t *testing.T) {
+			compressed, err := compress(algorithm, bomb)
+			if err != nil {
+				t.Fatalf("compress() error = %v", err)
+			}
+			if _, err := decompress(algorithm, compressed); !errors.Is(err, ErrPayloadTooLarge) {
+				t.Errorf("Expected ErrPayloadTooLarge, got %v", err)
+			}
+			if _, applied, _ := compressPayload(algorithm, 0, bomb); applied != CompressionNone {
+				t.Errorf("Expected a payload bigger than maxPayloadSize not to be compressed, got %s", applied)
+			}
+		})
+	}
+}
+
+func TestCompression_Threshold(t *testing.T) {
+	payload := []byte(strings.Repeat("a", 512))
+	_, applied, err := compressPayload(CompressionGzip, 1024, payload)
+	if err != nil {
+		t.Fatalf("compressPayload() error = %v", err)
+	}
+	if applied != CompressionNone {
+		t.Errorf("compressPayload() applied %s below the threshold", applied)
+	}
+
+	_, applied, err = compressPayload(CompressionGzip, 10, payload)
+	if err != nil {
+		t.Fatalf("compressPayload() error = %v", err)
+	}
+	if applied != CompressionGzip {
+		t.Errorf("compressPayload() applied %s, want %s", applied, CompressionGzip)
+	}
+}
+
+func TestCompression_Message(t *testing.T) {
+	value := strings.Repeat("compressible ", 200)
+	msg := &message{CacheName: "testCache", Key: "testKey", Value: value, compress: CompressionGzip}
+	data, err := msg.toUDP()
+	if err != nil {
+		t.Fatalf("ToUDP() error = %v", err)
+	}
+	if len(data) > 1024 {
+		t.Errorf("compressed message size %d does not fit in a datagram", len(data))
+	}
+
+	// the receiver does not need to know the compression of the sender
+	var decodedMsg message
+	if err := decodedMsg.fromUDP(data); err != nil {
+		t.Fatalf("FromUDP() error = %v", err)
+	}
+	if decodedMsg.Value != value {
+		t.Errorf("Value = %v, want %v", decodedMsg.Value, value)
+	}
+}
@@ -0,0 +1,294 @@
+package distributed_cache
+
+// In this file, you can find the conditional writes SetIfAbsent,
+// CompareAndSwap and Update, they read and write the entry atomically
+// in this node and write it to the Backend and the Writer as Set does.
+// With ConsistencyStrong the write is also checked against the conditional
+// writes of the other nodes: it is broadcast, the cache waits ConflictWindow
+// for conflicting writes based on the same version and only the newest one
+// is applied in all the nodes.
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Consistency is the guarantee of the conditional writes
+type Consistency uint8
+
+const (
+	// ConsistencyLocal makes conditional writes atomic in this node only,
+	// the result is replicated as a normal Set
+	ConsistencyLocal Consistency = iota
+	// ConsistencyStrong rejects a conditional write in every node
+	// when it lost a race against a conditional write of another node
+	ConsistencyStrong
+)
+
+// DefaultConflictWindow is the time a strong conditional write waits
+// for conflicting writes when the cache does not set ConflictWindow
+const DefaultConflictWindow = 50 * time.Millisecond
+
+// maxUpdateRetries is the number of times Update retries a lost race in
+// ConsistencyStrong, where each retry waits for the ConflictWindow
+const maxUpdateRetries = 8
+
+// maxLocalUpdateRetries is the number of times Update retries a lost race in ConsistencyLocal
+const maxLocalUpdateRetries = 100
+
+// ErrUpdateConflict is returned by Update when the key changed in every retry
+var ErrUpdateConflict = errors.New("update conflicted with other writes in every retry")
+
+// SetIfAbsent sets a value only if the key is not in the cache,
+// it returns true when the value was set
+func (c *Cache) SetIfAbsent(key string, value interface{}) bool {
+	if value == nil {
+		return false
+	}
+	_, ok, _ := c.swap(key, func(_ interface{}, exists bool, _ entryMeta) (interface{}, bool) {
+		return value, !exists
+	})
+	return ok
+}
+
+// CompareAndSwap sets a new value only if the current value is equal to old,
+// values are compared with reflect.DeepEqual and a nil old value matches a
+// missing key. A nil new value deletes the key. It returns true when the
+// value was swapped
+func (c *Cache) CompareAndSwap(key string, old, new interface{}) bool {
+	ok, _ := c.compareAndSwap(key, old, new)
+	return ok
+}
+
+// compareAndSwap is CompareAndSwap, it also returns the error of the Backend or the Writer
+func (c *Cache) compareAndSwap(key string, old, new interface{}) (bool, error) {
+	_, ok, err := c.swap(key, func(current interface{}, exists bool, _ entryMeta) (interface{}, bool) {
+		if !exists {
+			return new, old == nil
+		}
+		return new, reflect.DeepEqual(current, old)
+	})
+	return ok, err
+}
+
+// Update sets the value returned by fn, called with the current value of the
+// key or nil when it is not in the cache, a nil result deletes the key.
+// fn is called without locking the cache, so it can use it, and the result is
+// written only if the version of the key did not change: otherwise fn is called
+// again with the new value. It returns the value written, the error of the
+// Backend or the Writer, or ErrUpdateConflict when every retry lost
+func (c *Cache) Update(key string, fn func(old interface{}) interface{}) (interface{}, error) {
+	retries := maxLocalUpdateRetries
+	if c.Consistency == ConsistencyStrong {
+		retries = maxUpdateRetries
+	}
+	for i := 0; i < retries; i++ {
+		current, exists, meta := c.peek(key)
+		value := fn(current)
+		_, ok, err := c.swap(key, func(_ interface{}, stillExists bool, stillMeta entryMeta) (interface{}, bool) {
+			return value, stillExists == exists && stillMeta == meta
+		})
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return value, nil
+		}
+	}
+	return nil, ErrUpdateConflict
+}
+
+// peek returns the value of a key and its version without filling it,
+// asking the owners when this node does not own it, like swap
+func (c *Cache) peek(key string) (interface{}, bool, entryMeta) {
+	if !c.owns(key) {
+		if remote := c.askReply(key, c.Owners(key)); remote != nil {
+			return remote.Value, true, entryMeta{Version: remote.Version}
+		}
+		return nil, false, entryMeta{}
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	value, exists := c.getEntries().load(key)
+	return value, exists, c.meta[key]
+}
+
+// swap replaces the value of a key with the value returned by update, called with
+// the current value and version, when update returns true. It returns the value
+// of the key, if it was replaced and the error of the Backend or the Writer, that
+// are written before it is stored. A node that does not own the key calls update
+// with the value and version of the owners
+func (c *Cache) swap(key string, update func(current interface{}, exists bool, meta entryMeta) (interface{}, bool)) (interface{}, bool, error) {
+	owner := c.owns(key)
+	var remote *message
+	if !owner {
+		remote = c.askReply(key, c.Owners(key))
+	}
+	c.mutex.Lock()
+	for c.pending[key] && c.Consistency != ConsistencyStrong {
+		// a conditional write of this node is writing the key out
+		c.waitPending()
+	}
+	if c.pending[key] {
+		// a strong conditional write of this node is waiting for conflicts
+		c.mutex.Unlock()
+		return nil, false, nil
+	}
+	current, exists := c.getEntries().load(key)
+	meta := c.meta[key]
+	if !owner {
+		current, exists, meta = nil, false, entryMeta{}
+		if remote != nil {
+			current, exists, meta = remote.Value, true, entryMeta{Version: remote.Version}
+		}
+	}
+	base := meta.Version
+	value, ok := update(current, exists, meta)
+	if !ok {
+		c.mutex.Unlock()
+		return current, false, nil
+	}
+	message := c.newMessage(key, value)
+	if c.Consistency != ConsistencyStrong {
+		if err := c.writeOutPending(key, value); err != nil {
+			c.mutex.Unlock()
+			return current, false, err
+		}
+		c.write(key, value, owner)
+		if value == nil || !owner {
+			message.Version = c.deleted(key)
+		} else {
+			message.Version = c.touch(key, 0, false)
+		}
+		c.mutex.Unlock()
+		c.sendMessage(c.replicated(message))
+		return value, true, nil
+	}
+	message.Version = c.tick()
+	message.Base = base
+	message.Conditional = true
+	c.setPending(key, true)
+	c.mutex.Unlock()
+
+	c.sendMessage(c.replicated(message))
+	time.Sleep(c.getConflictWindow())
+
+	c.mutex.Lock()
+	won := c.wins(key, base, message, owner)
+	if !owner {
+		// the versions received during the window are only kept by the owners
+		delete(c.meta, key)
+	}
+	if !won {
+		c.setPending(key, false)
+		if owner {
+			current, _ = c.getEntries().load(key)
+		}
+		c.mutex.Unlock()
+		return current, false, nil
+	}
+	c.mutex.Unlock()
+	err := c.writeOut(key, value)
+	c.mutex.Lock()
+	c.setPending(key, false)
+	if err != nil {
+		// the other nodes applied the write, they get the previous value back
+		restore := c.newMessage(key, current)
+		restore.Version = c.tick()
+		c.mutex.Unlock()
+		c.logError("conditional write failed", err, "key", key)
+		c.sendMessage(c.replicated(restore))
+		return current, false, err
+	}
+	c.write(key, value, owner)
+	if value != nil && owner {
+		c.setMeta(key, entryMeta{Version: message.Version, Node: c.node, Base: base, Conditional: true})
+	} else if owner {
+		c.bury(key, entryMeta{Version: message.Version, Node: c.node, Base: base, Conditional: true})
+	}
+	c.mutex.Unlock()
+	return value, true, nil
+}
+
+// writeOutPending writes a value to the Backend and the Writer, unlocking the
+// mutex while the key is pending, and logs its error. The mutex must be locked
+func (c *Cache) writeOutPending(key string, value interface{}) error {
+	if c.Backend == nil && c.Writer == nil {
+		return nil
+	}
+	c.setPending(key, true)
+	c.mutex.Unlock()
+	err := c.writeOut(key, value)
+	c.mutex.Lock()
+	c.setPending(key, false)
+	if err != nil {
+		c.logError("conditional write failed", err, "key", key)
+	}
+	return err
+}
+
+// setPending marks a key with a conditional write of this node in progress,
+// the mutex must be locked
+func (c *Cache) setPending(key string, pending bool) {
+	if pending {
+		if c.pending == nil {
+			c.pending = make(map[string]bool)
+		}
+		c.pending[key] = true
+		return
+	}
+	delete(c.pending, key)
+	if c.pendingDone != nil {
+		c.pendingDone.Broadcast()
+	}
+}
+
+// waitPending waits until a pending key is released, the mutex must be locked
+func (c *Cache) waitPending() {
+	if c.pendingDone == nil {
+		c.pendingDone = sync.NewCond(&c.mutex)
+	}
+	c.pendingDone.Wait()
+}
+
+// wins returns true when a strong conditional write based on base
+// was not superseded during the conflict window, the mutex must be locked.
+// A node that does not own the key only knows the writes received during the window
+func (c *Cache) wins(key string, base uint64, message *message, owner bool) bool {
+	current, exists := c.meta[key]
+	if !exists {
+		// an invalidation received during the window removed the key
+		current, exists = c.buriedMeta(key)
+	}
+	if !exists {
+		if !owner {
+			return true
+		}
+		_, stored := c.getEntries().load(key)
+		return base == 0 && !stored
+	}
+	if current.Version == base {
+		return true
+	}
+	return current.Conditional && current.Base == base &&
+		current.olderThan(message.Version, message.Node)
+}
+
+// write stores or removes a value, a node that does not own the key removes it,
+// the mutex must be locked
+func (c *Cache) write(key string, value interface{}, owner bool) {
+	if value == nil || !owner {
+		c.getEntries().remove(key)
+	} else {
+		c.getEntries().store(key, value)
+	}
+}
+
+func (c *Cache) getConflictWindow() time.Duration {
+	if c.ConflictWindow <= 0 {
+		return DefaultConflictWindow
+	}
+	return c.ConflictWindow
+}
@@ -0,0 +1,230 @@
+package distributed_cache
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCache_SetIfAbsent(t *testing.T) {
+	cache.Delete("cas1")
+	if !cache.SetIfAbsent("cas1", "value1") {
+		t.Errorf("Expected SetIfAbsent to set a missing key")
+	}
+	if cache.SetIfAbsent("cas1", "value2") {
+		t.Errorf("Expected SetIfAbsent to fail on an existing key")
+	}
+	if val := cache.Get("cas1"); val != "value1" {
+		t.Errorf("Expected value1, got %v", val)
+	}
+}
+
+func TestCache_CompareAndSwap(t *testing.T) {
+	cache.Set("cas2", "value1")
+	if cache.CompareAndSwap("cas2", "other", "value2") {
+		t.Errorf("Expected CompareAndSwap to fail with a different old value")
+	}
+	if !cache.CompareAndSwap("cas2", "value1", "value2") {
+		t.Errorf("Expected CompareAndSwap to swap")
+	}
+	if val := cache.Get("cas2"); val != "value2" {
+		t.Errorf("Expected value2, got %v", val)
+	}
+}
+
+func TestLRUCache_Update(t *testing.T) {
+	lruCache.Delete("counter")
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lruCache.Update("counter", func(old interface{}) interface{} {
+				if old == nil {
+					return 1
+				}
+				return old.(int) + 1
+			})
+		}()
+	}
+	wg.Wait()
+	if val := lruCache.Get("counter"); val != 50 {
+		t.Errorf("Expected 50, got %v", val)
+	}
+}
+
+func TestCache_StrongCompareAndSwapLosesRace(t *testing.T) {
+	strong := NewCache("strongCache", "255.255.255.255", ":12348")
+	defer strong.StopListener()
+	strong.Consistency = ConsistencyStrong
+	strong.ConflictWindow = 100 * time.Millisecond
+	strong.Set("key", "value1")
+
+	// another node swaps the same version during the conflict window
+	winner := uuid.UUID{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		strong.applyRemote(&message{CacheName: "strongCache", Node: winner, Key: "key", Value: "remote",
+			Version: 100, Base: 1, Conditional: true})
+	}()
+
+	if strong.CompareAndSwap("key", "value1", "local") {
+		t.Errorf("Expected CompareAndSwap to lose the race")
+	}
+	if val := strong.Get("key"); val != "remote" {
+		t.Errorf("Expected remote, got %v", val)
+	}
+}
+
+func TestCache_StrongCompareAndSwapWinsRace(t *testing.T) {
+	strong := NewCache("strongCache", "255.255.255.255", ":12349")
+	defer strong.StopListener()
+	strong.Consistency = ConsistencyStrong
+	strong.ConflictWindow = 100 * time.Millisecond
+	strong.Set("key", "value1")
+
+	// the conflicting write of another node is older, so it loses
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		strong.applyRemote(&message{CacheName: "strongCache", Node: uuid.Nil, Key: "key", Value: "remote",
+			Version: 2, Base: 1, Conditional: true})
+	}()
+
+	if !strong.CompareAndSwap("key", "value1", "local") {
+		t.Errorf("Expected CompareAndSwap to win the race")
+	}
+	if val := strong.Get("key"); val != "local" {
+		t.Errorf("Expected local, got %v", val)
+	}
+}
+
+func TestCache_ConditionalWritesWriteOut(t *testing.T) {
+	writer := newRecordingWriter()
+	writer.failing["bad"] = true
+	backend := NewMemoryBackend()
+	c := NewCache("conditionalWriteOut", "255.255.255.255", ":12504")
+	defer c.StopListener()
+	c.Writer = writer
+	c.Backend = backend
+
+	if !c.SetIfAbsent("key1", "value1") || !c.CompareAndSwap("key1", "value1", "value2") {
+		t.Fatalf("Expected the conditional writes to succeed")
+	}
+	if value, found, _ := backend.Get("key1"); !found || value != "value2" {
+		t.Errorf("Expected value2 in the backend, got %v", value)
+	}
+	if writer.values["key1"] != "value2" {
+		t.Errorf("Expected value2 in the writer, got %v", writer.values["key1"])
+	}
+	if c.SetIfAbsent("bad", "value") {
+		t.Errorf("Expected the failed write not to be set")
+	}
+	c.mutex.Lock()
+	_, cached := c.storage["bad"]
+	c.mutex.Unlock()
+	if cached {
+		t.Errorf("Expected the failed write not to be cached")
+	}
+	if val := c.Incr("counter", 2); val != 2 {
+		t.Errorf("Expected 2, got %v", val)
+	}
+	if counter, _ := writer.values["counter"].(*PNCounter); counter.Value() != 2 {
+		t.Errorf("Expected the counter in the writer, got %v", writer.values["counter"])
+	}
+}
+
+func TestCache_UpdateUsesTheCache(t *testing.T) {
+	c := NewCache("updateReentrant", "255.255.255.255", ":12505")
+	defer c.StopListener()
+	c.Set("step", 2)
+	done := make(chan interface{})
+	go func() {
+		value, _ := c.Update("total", func(old interface{}) interface{} {
+			// fn runs without the lock, so it can read the cache
+			step := c.Get("step").(int)
+			if old == nil {
+				return step
+			}
+			return old.(int) + step
+		})
+		done <- value
+	}()
+	select {
+	case value := <-done:
+		if value != 2 {
+			t.Errorf("Expected 2, got %v", value)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected Update not to deadlock")
+	}
+}
+
+func TestCache_UpdateComparesVersions(t *testing.T) {
+	c := NewCache("updateVersions", "255.255.255.255", ":12519")
+	defer c.StopListener()
+	// NaN is not equal to itself, the version of the key is
+	c.Set("ratio", math.NaN())
+	done := make(chan error)
+	go func() {
+		_, err := c.Update("ratio", func(old interface{}) interface{} { return 1.0 })
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil || c.Get("ratio") != 1.0 {
+			t.Errorf("Expected 1 and no error, got %v and %v", c.Get("ratio"), err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected Update not to spin on a NaN")
+	}
+
+	// the key changes in every retry
+	calls := 0
+	_, err := c.Update("ratio", func(old interface{}) interface{} {
+		calls++
+		c.Set("ratio", float64(calls))
+		return 0.0
+	})
+	if !errors.Is(err, ErrUpdateConflict) || calls != maxLocalUpdateRetries {
+		t.Errorf("Expected ErrUpdateConflict after %d calls, got %v after %d", maxLocalUpdateRetries, err, calls)
+	}
+}
+
+func TestCache_StrongCompareAndSwapSendsInvalidation(t *testing.T) {
+	strong := NewCache("strongInvalidated", "255.255.255.255", ":12527")
+	defer strong.StopListener()
+	conn := &recordingConn{}
+	strong.senderOnce.Do(func() { strong.startSender(conn) })
+	strong.Consistency = ConsistencyStrong
+	strong.Replication = ReplicationInvalidate
+	strong.ConflictWindow = 50 * time.Millisecond
+	strong.FlushInterval = 10 * time.Millisecond
+	strong.Set("key", "value1")
+
+	if !strong.CompareAndSwap("key", "value1", "value2") {
+		t.Fatalf("Expected CompareAndSwap to swap the value")
+	}
+	time.Sleep(100 * time.Millisecond)
+	proposals := 0
+	for _, datagram := range conn.received() {
+		var received message
+		if err := received.decodeEnvelope(datagram); err != nil {
+			t.Fatalf("decodeEnvelope() error = %v", err)
+		}
+		for _, m := range received.messages() {
+			if !m.Conditional {
+				continue
+			}
+			proposals++
+			if m.Op != opInvalidate || m.hasValue {
+				t.Errorf("Expected the conditional write to be sent as an invalidation, got %+v", m)
+			}
+		}
+	}
+	if proposals == 0 {
+		t.Errorf("Expected the conditional write to be sent")
+	}
+}
@@ -0,0 +1,227 @@
+package distributed_cache
+
+// In this file, you can find Config, the settings of a cache that can be
+// loaded from a JSON or YAML file and from environment variables, so the caches
+// can be tuned without changing the code. NewFromConfig validates it and
+// creates the cache with New, the functions like the Filler are still given
+// as options.
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config are the settings of a cache
+type Config struct {
+	Name        string   `json:"name" yaml:"name"`
+	Broadcast   string   `json:"broadcast" yaml:"broadcast"` // DefaultBroadcast when empty
+	Port        int      `json:"port" yaml:"port"`
+	MaxEntries  int      `json:"max_entries" yaml:"max_entries"` // unbounded when 0
+	TTL         Duration `json:"ttl" yaml:"ttl"`                 // no TTL when 0
+	SecurityKey string   `json:"security_key" yaml:"security_key"`
+	Codec       string   `json:"codec" yaml:"codec"`             // gob (default), json or msgpack
+	Compression string   `json:"compression" yaml:"compression"` // none (default), gzip or flate
+	// Time a message waits to be sent with other messages
+	FlushInterval Duration `json:"flush_interval" yaml:"flush_interval"`
+}
+
+// Duration is a time.Duration written as a string like "1m30s"
+// or as a number of seconds in the configuration files
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	return d.set(value)
+}
+
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return time.Duration(d).String(), nil
+}
+
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	var value interface{}
+	if err := node.Decode(&value); err != nil {
+		return err
+	}
+	return d.set(value)
+}
+
+// set sets the duration from a string or a number of seconds
+func (d *Duration) set(value interface{}) error {
+	switch v := value.(type) {
+	case string:
+		duration, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*d = Duration(duration)
+	case float64:
+		*d = Duration(v * float64(time.Second))
+	case int:
+		*d = Duration(time.Duration(v) * time.Second)
+	default:
+		return fmt.Errorf("invalid duration %v", value)
+	}
+	return nil
+}
+
+// LoadConfig reads a Config from a JSON file or a YAML file (.yaml or .yml),
+// the unknown fields are errors
+func LoadConfig(path string) (Config, error) {
+	var config Config
+	err := LoadFile(path, &config)
+	return config, err
+}
+
+// LoadFile decodes a JSON file or a YAML file (.yaml or .yml) into v like LoadConfig,
+// for the files that have a Config among other settings
+func LoadFile(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(v)
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(v)
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+	default:
+		return fmt.Errorf("%s: unknown configuration format %q", path, filepath.Ext(path))
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// LoadEnv overrides the settings with the environment variables that are set,
+// named with the prefix and the field: PREFIX_NAME, PREFIX_BROADCAST, PREFIX_PORT,
+// PREFIX_MAX_ENTRIES, PREFIX_TTL, PREFIX_SECURITY_KEY, PREFIX_CODEC,
+// PREFIX_COMPRESSION and PREFIX_FLUSH_INTERVAL
+func (c *Config) LoadEnv(prefix string) error {
+	fields := []struct {
+		name  string
+		field interface{}
+	}{
+		{"NAME", &c.Name}, {"BROADCAST", &c.Broadcast}, {"PORT", &c.Port}, {"MAX_ENTRIES", &c.MaxEntries},
+		{"TTL", &c.TTL}, {"SECURITY_KEY", &c.SecurityKey}, {"CODEC", &c.Codec},
+		{"COMPRESSION", &c.Compression}, {"FLUSH_INTERVAL", &c.FlushInterval},
+	}
+	for _, f := range fields {
+		name := prefix + "_" + f.name
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		switch field := f.field.(type) {
+		case *string:
+			*field = value
+		case *int:
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return &ConfigError{Field: name, Reason: "must be an integer"}
+			}
+			*field = n
+		case *Duration:
+			// a bare integer is a number of seconds, like in the files
+			var duration interface{} = value
+			if seconds, err := strconv.Atoi(value); err == nil {
+				duration = seconds
+			}
+			if err := field.set(duration); err != nil {
+				return &ConfigError{Field: name, Reason: "must be a duration like 30s or a number of seconds"}
+			}
+		}
+	}
+	return nil
+}
+
+// Validate returns a ConfigError naming the first field that is not valid
+func (c Config) Validate() error {
+	_, err := c.Options()
+	return err
+}
+
+// Options returns the options of New for the settings
+func (c Config) Options() ([]Option, error) {
+	if c.Name == "" {
+		return nil, &ConfigError{Field: "name", Reason: "must not be empty"}
+	}
+	if c.Port <= 0 || c.Port > 65535 {
+		return nil, &ConfigError{Field: "port", Reason: "must be between 1 and 65535"}
+	}
+	if c.MaxEntries < 0 {
+		return nil, &ConfigError{Field: "max_entries", Reason: "must not be negative"}
+	}
+	if c.TTL < 0 {
+		return nil, &ConfigError{Field: "ttl", Reason: "must not be negative"}
+	}
+	if c.FlushInterval < 0 {
+		return nil, &ConfigError{Field: "flush_interval", Reason: "must not be negative"}
+	}
+	if c.SecurityKey != "" && len(c.SecurityKey) < MinKeySize {
+		return nil, &ConfigError{Field: "security_key", Reason: fmt.Sprintf("must have at least %d bytes", MinKeySize)}
+	}
+	options := []Option{
+		WithTransport(c.Broadcast, ":"+strconv.Itoa(c.Port)),
+		WithMaxEntries(c.MaxEntries),
+		WithTTL(time.Duration(c.TTL)),
+		WithBatching(time.Duration(c.FlushInterval), 0),
+	}
+	if c.SecurityKey != "" {
+		options = append(options, WithSecurity([]byte(c.SecurityKey)))
+	}
+	switch c.Codec {
+	case "", "gob":
+	case "json":
+		options = append(options, WithCodec(JSONCodec{}))
+	case "msgpack":
+		options = append(options, WithCodec(MsgPackCodec{}))
+	default:
+		return nil, &ConfigError{Field: "codec", Reason: fmt.Sprintf("unknown codec %q, use gob, json or msgpack", c.Codec)}
+	}
+	switch c.Compression {
+	case "", "none":
+	case "gzip":
+		options = append(options, WithCompression(CompressionGzip, 0))
+	case "flate":
+		options = append(options, WithCompression(CompressionFlate, 0))
+	default:
+		return nil, &ConfigError{Field: "compression",
+			Reason: fmt.Sprintf("unknown compression %q, use none, gzip or flate", c.Compression)}
+	}
+	return options, nil
+}
+
+// NewFromConfig creates a cache with New from the settings,
+// the options are applied after the ones of the settings
+func NewFromConfig(config Config, opts ...Option) (*Cache, error) {
+	options, err := config.Options()
+	if err != nil {
+		return nil, err
+	}
+	return New(config.Name, append(options, opts...)...)
+}
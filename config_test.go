@@ -0,0 +1,102 @@
+package distributed_cache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	expected := Config{Name: "configCache", Port: 12389, MaxEntries: 10, TTL: Duration(90 * time.Second),
+		Codec: "json"}
+	files := map[string]string{
+		"cache.json": `{"name": "configCache", "port": 12389, "max_entries": 10, "ttl": "1m30s", "codec": "json"}`,
+		"cache.yaml": "name: configCache\nport: 12389\nmax_entries: 10\nttl: 90\ncodec: json\n",
+	}
+	for name, content := range files {
+		config, err := LoadConfig(writeConfig(t, name, content))
+		if err != nil {
+			t.Fatalf("Expected no error loading %s, got %v", name, err)
+		}
+		if config != expected {
+			t.Errorf("Expected %+v from %s, got %+v", expected, name, config)
+		}
+	}
+	if _, err := LoadConfig(writeConfig(t, "cache.yml", "name: configCache\nmax_entrie: 10\n")); err == nil {
+		t.Errorf("Expected an error for an unknown field")
+	}
+}
+
+func TestConfig_LoadEnv(t *testing.T) {
+	config := Config{Name: "configCache", Port: 1}
+	t.Setenv("DCACHE_PORT", "12389")
+	t.Setenv("DCACHE_TTL", "2m")
+	t.Setenv("DCACHE_SECURITY_KEY", "0123456789abcdef")
+	if err := config.LoadEnv("DCACHE"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if config.Port != 12389 || config.TTL != Duration(2*time.Minute) || config.SecurityKey != "0123456789abcdef" {
+		t.Errorf("Expected the settings of the environment, got %+v", config)
+	}
+	t.Setenv("DCACHE_TTL", "90")
+	if err := config.LoadEnv("DCACHE"); err != nil || config.TTL != Duration(90*time.Second) {
+		t.Errorf("Expected a TTL of 90 seconds, got %v and %v", time.Duration(config.TTL), err)
+	}
+	t.Setenv("DCACHE_MAX_ENTRIES", "ten")
+	var configErr *ConfigError
+	if err := config.LoadEnv("DCACHE"); !errors.As(err, &configErr) || configErr.Field != "DCACHE_MAX_ENTRIES" {
+		t.Errorf("Expected an error of DCACHE_MAX_ENTRIES, got %v", err)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	valid := Config{Name: "configCache", Port: 12389}
+	tests := map[string]func(c *Config){
+		"name":         func(c *Config) { c.Name = "" },
+		"port":         func(c *Config) { c.Port = 70000 },
+		"max_entries":  func(c *Config) { c.MaxEntries = -1 },
+		"ttl":          func(c *Config) { c.TTL = -1 },
+		"security_key": func(c *Config) { c.SecurityKey = "short" },
+		"codec":        func(c *Config) { c.Codec = "xml" },
+		"compression":  func(c *Config) { c.Compression = "zstd" },
+	}
+	for field, change := range tests {
+		config := valid
+		change(&config)
+		var configErr *ConfigError
+		if err := config.Validate(); !errors.As(err, &configErr) || configErr.Field != field {
+			t.Errorf("Expected an error of %s, got %v", field, err)
+		}
+	}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestNewFromConfig(t *testing.T) {
+	config := Config{Name: "configCache", Port: 12389, MaxEntries: 2, TTL: Duration(time.Minute)}
+	c, err := NewFromConfig(config, WithFiller(func(key string) (interface{}, error) {
+		return "filled", nil
+	}))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer c.StopListener()
+	ttlCache, ok := c.entries.(*LRUCacheWithTTL)
+	if !ok || ttlCache.MaxEntries != 2 || ttlCache.TTL != time.Minute {
+		t.Errorf("Expected an LRUCacheWithTTL of 2 entries and 1m, got %T", c.entries)
+	}
+	if val := c.Get("key"); val != "filled" {
+		t.Errorf("Expected filled, got %v", val)
+	}
+}
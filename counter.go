@@ -0,0 +1,124 @@
+package distributed_cache
+
+// In this file, you can find the PNCounter CRDT and the methods Incr, Decr,
+// IncrWindow and Counter. Every node counts its own increments and decrements,
+// so concurrent increments in different nodes are never lost.
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// PNCounter is a counter that can be incremented and decremented
+// in every node at the same time. P and N keep the increments and decrements
+// of each node, keyed by the node UUID
+type PNCounter struct {
+	P map[string]int64
+	N map[string]int64
+}
+
+// Value returns the value of the counter
+func (p *PNCounter) Value() int64 {
+	if p == nil {
+		return 0
+	}
+	var value int64
+	for _, count := range p.P {
+		value += count
+	}
+	for _, count := range p.N {
+		value -= count
+	}
+	return value
+}
+
+// Merge returns a counter with the maximum count of each node of both counters
+func (p *PNCounter) Merge(other CRDT) CRDT {
+	merged := &PNCounter{P: make(map[string]int64), N: make(map[string]int64)}
+	for _, counter := range []*PNCounter{p, toPNCounter(other)} {
+		if counter == nil {
+			continue
+		}
+		mergeMax(merged.P, counter.P)
+		mergeMax(merged.N, counter.N)
+	}
+	return merged
+}
+
+// add returns the delta that adds a value to the count of the node,
+// the new counts of the node
+func (p *PNCounter) add(node string, value int64) *PNCounter {
+	delta := &PNCounter{P: map[string]int64{}, N: map[string]int64{}}
+	if p != nil {
+		delta.P[node] = p.P[node]
+		delta.N[node] = p.N[node]
+	}
+	if value >= 0 {
+		delta.P[node] += value
+	} else {
+		delta.N[node] -= value
+	}
+	return delta
+}
+
+func toPNCounter(value CRDT) *PNCounter {
+	counter, _ := value.(*PNCounter)
+	return counter
+}
+
+// mergeMax keeps the maximum count of each node
+func mergeMax(target, source map[string]int64) {
+	for node, count := range source {
+		if count > target[node] {
+			target[node] = count
+		}
+	}
+}
+
+// Incr adds delta to the counter of a key and sends the change to the other nodes,
+// it returns the new value of the counter. A value of another type is replaced
+func (c *Cache) Incr(key string, delta int64) int64 {
+	return c.IncrContext(context.Background(), key, delta)
+}
+
+// IncrContext is Incr with the context of the caller, see SetContext
+func (c *Cache) IncrContext(ctx context.Context, key string, delta int64) int64 {
+	value, _ := c.mutate(ctx, key, func(current interface{}) CRDT {
+		counter, _ := current.(*PNCounter)
+		return counter.add(c.node.String(), delta)
+	})
+	counter, _ := value.(*PNCounter)
+	return counter.Value()
+}
+
+// Decr subtracts delta from the counter of a key, see Incr
+func (c *Cache) Decr(key string, delta int64) int64 {
+	return c.Incr(key, -delta)
+}
+
+// IncrWindow adds delta to the counter of a key in the current fixed window,
+// the counter starts from zero in each window. Used with LRUCacheWithTTL
+// the counters of the old windows expire with the TTL
+func (c *Cache) IncrWindow(key string, delta int64, window time.Duration) int64 {
+	return c.Incr(windowKey(key, window, time.Now()), delta)
+}
+
+// CounterWindow returns the value of the counter of a key in the current fixed window
+func (c *Cache) CounterWindow(key string, window time.Duration) int64 {
+	return c.Counter(windowKey(key, window, time.Now()))
+}
+
+// Counter returns the value of the counter of a key, 0 when it is not a counter
+func (c *Cache) Counter(key string) int64 {
+	counter, _ := c.readCRDT(key).(*PNCounter)
+	return counter.Value()
+}
+
+// windowKey returns the key of the counter of the window that contains now
+func windowKey(key string, window time.Duration, now time.Time) string {
+	if window <= 0 {
+		return key
+	}
+	return fmt.Sprintf("%s@%d", key, now.UnixNano()/int64(window))
+}
@@ -0,0 +1,152 @@
+package distributed_cache
+
+import (
+	"github.com/google/uuid"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCache_Incr(t *testing.T) {
+	cache.Delete("hits")
+	if val := cache.Incr("hits", 5); val != 5 {
+		t.Errorf("Expected 5, got %v", val)
+	}
+	if val := cache.Decr("hits", 2); val != 3 {
+		t.Errorf("Expected 3, got %v", val)
+	}
+	if val := cache.Counter("hits"); val != 3 {
+		t.Errorf("Expected 3, got %v", val)
+	}
+}
+
+func TestLRUCache_IncrConcurrent(t *testing.T) {
+	lruCache.Delete("hits")
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lruCache.Incr("hits", 1)
+		}()
+	}
+	wg.Wait()
+	if val := lruCache.Counter("hits"); val != 100 {
+		t.Errorf("Expected 100, got %v", val)
+	}
+}
+
+func TestCache_IncrMergesRemoteIncrements(t *testing.T) {
+	c := NewCache("remoteCounters", "255.255.255.255", ":12517")
+	defer c.StopListener()
+	c.Incr("remote-hits", 2)
+
+	// another node incremented the same counter concurrently
+	remote := uuid.New().String()
+	delta := &PNCounter{P: map[string]int64{remote: 3}, N: map[string]int64{remote: 1}}
+	c.applyRemote(&message{CacheName: c.Name, Key: "remote-hits", Value: delta, Merge: true})
+	// a duplicated delta does not change the counter
+	c.applyRemote(&message{CacheName: c.Name, Key: "remote-hits", Value: delta, Merge: true})
+
+	if val := c.Counter("remote-hits"); val != 4 {
+		t.Errorf("Expected 4, got %v", val)
+	}
+}
+
+func TestPNCounter_MergeIsCommutative(t *testing.T) {
+	a := &PNCounter{P: map[string]int64{"a": 3}, N: map[string]int64{}}
+	b := &PNCounter{P: map[string]int64{"a": 1, "b": 2}, N: map[string]int64{"b": 1}}
+
+	ab := a.Merge(b).(*PNCounter)
+	ba := b.Merge(a).(*PNCounter)
+	if ab.Value() != 4 || ba.Value() != 4 {
+		t.Errorf("Expected 4, got %v and %v", ab.Value(), ba.Value())
+	}
+	if a.Value() != 3 {
+		t.Errorf("Merge modified the receiver")
+	}
+}
+
+func TestCache_IncrWindow(t *testing.T) {
+	now := time.Now()
+	if windowKey("limit", time.Minute, now) != windowKey("limit", time.Minute, now.Truncate(time.Minute)) {
+		t.Errorf("Expected the same key in the same window")
+	}
+	if windowKey("limit", time.Minute, now) == windowKey("limit", time.Minute, now.Add(time.Minute)) {
+		t.Errorf("Expected a different key in the next window")
+	}
+
+	cache.IncrWindow("limit", 1, time.Hour)
+	if val := cache.CounterWindow("limit", time.Hour); val < 1 {
+		t.Errorf("Expected at least 1, got %v", val)
+	}
+}
+
+func TestMessage_MergeIgnoresCacheCodec(t *testing.T) {
+	delta := &PNCounter{P: map[string]int64{"a": 1}, N: map[string]int64{}}
+	msg := &message{CacheName: "testCache", Key: "hits", Value: delta, Merge: true, codec: JSONCodec{}}
+	data, err := msg.toUDP()
+	if err != nil {
+		t.Fatalf("ToUDP() error = %v", err)
+	}
+
+	decodedMsg := message{codec: JSONCodec{}}
+	if err := decodedMsg.fromUDP(data); err != nil {
+		t.Fatalf("FromUDP() error = %v", err)
+	}
+	if counter, ok := decodedMsg.Value.(*PNCounter); !ok || counter.Value() != 1 {
+		t.Errorf("Expected a counter of 1, got %#v", decodedMsg.Value)
+	}
+}
+
+func TestCache_IncrRebuildsFromPeers(t *testing.T) {
+	c, err := New("rebuiltCounters", WithTransport("", ":12512"), WithMaxEntries(1))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer c.Close()
+	c.ForwardTimeout = time.Second
+	c.Incr("hits", 5)
+	remote := uuid.New().String()
+	copied := &PNCounter{P: map[string]int64{c.node.String(): 5, remote: 2}, N: map[string]int64{}}
+
+	// the counter is evicted and the other node answers with its copy
+	c.Incr("other", 1)
+	go func() {
+		for {
+			c.requestsMutex.Lock()
+			for id := range c.requests {
+				c.requestsMutex.Unlock()
+				c.deliverReply(&message{Key: "hits", Value: copied, Op: opGetReply, Request: id})
+				return
+			}
+			c.requestsMutex.Unlock()
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	if val := c.Incr("hits", 1); val != 8 {
+		t.Errorf("Expected 8, got %v", val)
+	}
+	c.mutex.Lock()
+	value, _ := c.load("hits")
+	c.mutex.Unlock()
+	if count := value.(*PNCounter).P[c.node.String()]; count != 6 {
+		t.Errorf("Expected the count of the node to continue from 5, got %v", count)
+	}
+}
+
+func TestCache_IncrNewKeyDoesNotWait(t *testing.T) {
+	c, err := New("newCounters", WithTransport("", ":12518"), WithMaxEntries(1))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer c.Close()
+	c.ForwardTimeout = time.Second
+	start := time.Now()
+	c.Incr("hits", 1)
+	c.IncrWindow("limit", 1, time.Minute)
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Expected the first increments not to wait for the other nodes, took %v", elapsed)
+	}
+}
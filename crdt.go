@@ -0,0 +1,191 @@
+package distributed_cache
+
+// In this file, you can find the CRDT interface and the methods used to
+// replicate CRDT values. A CRDT value is mutated with a delta that is merged
+// with the local value and sent to the other nodes, that merge it with their
+// own copy instead of overwriting it, so concurrent changes are not lost.
+// A node that evicted a value, because of its capacity or its TTL, rebuilds it
+// from the copies of the other nodes before changing it again, so its own
+// counts continue from the ones the other nodes know. A restarted node has a
+// new UUID, so its counts start from zero without losing the old ones.
+
+import (
+	"context"
+	"encoding/gob"
+	"reflect"
+	"slices"
+)
+
+// CRDT is a value that can be merged with the copies of the other nodes.
+// Merge must be commutative, associative and idempotent,
+// and must return a new value without modifying the receiver or other.
+type CRDT interface {
+	Merge(other CRDT) CRDT
+}
+
+// crdtCodec encodes the CRDT deltas, regardless of the codec of the cache,
+// the CRDT types of this package are registered in init,
+// other CRDT types must be registered with gob.Register in every node
+var crdtCodec Codec = GobCodec{}
+
+func init() {
+	gob.Register(&PNCounter{})
+	gob.Register(&ORSet{})
+	gob.Register(&LWWMap{})
+	gob.Register(&MVRegister{})
+}
+
+// mergeValue merges a delta with the current value of a key,
+// the delta replaces values that are not of the same type
+func mergeValue(current interface{}, delta CRDT) CRDT {
+	value, ok := current.(CRDT)
+	if !ok || !sameType(value, delta) {
+		return delta.Merge(nil)
+	}
+	return value.Merge(delta)
+}
+
+// sameType returns true when both values have the same dynamic type
+func sameType(a, b interface{}) bool {
+	return reflect.TypeOf(a) == reflect.TypeOf(b)
+}
+
+// Merge merges a delta with the CRDT value of a key and sends the delta
+// to the other nodes, that merge it with their own value. It returns the new value,
+// or the current one when the Backend or the Writer fail
+func (c *Cache) Merge(key string, delta CRDT) CRDT {
+	return c.MergeContext(context.Background(), key, delta)
+}
+
+// MergeContext is Merge with the context of the caller, see SetContext
+func (c *Cache) MergeContext(ctx context.Context, key string, delta CRDT) CRDT {
+	value, _ := c.mutate(ctx, key, func(interface{}) CRDT {
+		return delta
+	})
+	return value
+}
+
+// mutate merges the delta returned by change, called with the current value
+// of a key, writes the result to the Backend and the Writer, stores it and
+// sends the delta to the other nodes. A node that does not own the key merges
+// the delta with the value of the owners and does not store it.
+// It returns the new value, or the current one and the error of the write
+func (c *Cache) mutate(ctx context.Context, key string, change func(current interface{}) CRDT) (CRDT, error) {
+	ctx, span := c.startWrite(ctx, SpanMerge, key)
+	defer span.End()
+	owner := c.owns(key)
+	var current interface{}
+	if !owner {
+		current, _ = c.forwardGet(key)
+	} else {
+		c.rebuild(key)
+	}
+	c.mutex.Lock()
+	if owner {
+		current, _ = c.getEntries().load(key)
+	}
+	delta := change(current)
+	value := mergeValue(current, delta)
+	if c.Backend != nil || c.Writer != nil {
+		c.mutex.Unlock()
+		if err := c.writeOut(key, value); err != nil {
+			c.logError("CRDT write failed", err, "key", key)
+			span.RecordError(err)
+			currentCRDT, _ := current.(CRDT)
+			return currentCRDT, err
+		}
+		c.mutex.Lock()
+		if owner {
+			// merged again with the changes made while it was written out
+			current, _ = c.getEntries().load(key)
+			value = mergeValue(current, delta)
+		}
+	}
+	if owner {
+		c.getEntries().store(key, value)
+	}
+	c.mutex.Unlock()
+	message := c.newMessage(key, delta)
+	message.Merge = true
+	c.sendMessageContext(ctx, message)
+	return value, nil
+}
+
+// maxLostKeys is the number of evicted CRDT values that are remembered
+const maxLostKeys = 4096
+
+// evict remembers a CRDT value that is evicted while the other nodes may
+// still have it, so it is rebuilt before it changes, the mutex must be locked
+func (c *Cache) evict(key string) {
+	if _, ok := c.storage[key].(CRDT); !ok || c.lost[key] {
+		return
+	}
+	if c.lost == nil {
+		c.lost = make(map[string]bool)
+	}
+	if len(c.lostOrder) >= maxLostKeys {
+		delete(c.lost, c.lostOrder[0])
+		c.lostOrder = c.lostOrder[1:]
+	}
+	c.lost[key] = true
+	c.lostOrder = append(c.lostOrder, key)
+}
+
+// forget forgets an evicted CRDT value that was deleted in every node,
+// the mutex must be locked
+func (c *Cache) forget(key string) {
+	if !c.lost[key] {
+		return
+	}
+	delete(c.lost, key)
+	c.lostOrder = slices.DeleteFunc(c.lostOrder, func(lost string) bool { return lost == key })
+}
+
+// rebuild merges the copy of another node with a CRDT value evicted by this
+// node, the other values are not asked for. The mutex must not be locked
+func (c *Cache) rebuild(key string) {
+	c.mutex.Lock()
+	lost := c.lost[key]
+	c.forget(key)
+	c.mutex.Unlock()
+	if !lost {
+		return
+	}
+	reply := c.askAny(key)
+	if reply == nil {
+		return
+	}
+	remote, ok := reply.Value.(CRDT)
+	if !ok {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entries := c.getEntries()
+	current, _ := entries.load(key)
+	entries.store(key, mergeValue(current, remote))
+}
+
+// applyMerge merges a delta received from another node, the mutex must be locked
+func (c *Cache) applyMerge(message *message) {
+	delta, ok := message.Value.(CRDT)
+	if !ok || !c.owns(message.Key) {
+		return
+	}
+	entries := c.getEntries()
+	current, _ := entries.load(message.Key)
+	entries.store(message.Key, mergeValue(current, delta))
+}
+
+// readCRDT returns the value of a key, asking the owners when this node
+// does not own it, the mutex must not be locked
+func (c *Cache) readCRDT(key string) interface{} {
+	if !c.owns(key) {
+		value, _ := c.forwardGet(key)
+		return value
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	value, _ := c.getEntries().load(key)
+	return value
+}
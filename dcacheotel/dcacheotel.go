@@ -0,0 +1,109 @@
+// Package dcacheotel is an adapter to trace the caches of distributed_cache
+// with OpenTelemetry. The trace context is propagated between the nodes
+// with the TextMapPropagator, W3C trace context by default.
+package dcacheotel
+
+import (
+	"context"
+	"fmt"
+	"github.com/diogenes-moreira/distributed-cache"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+// Tracer is a distributed_cache.Tracer that creates OpenTelemetry spans
+type Tracer struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+// New creates a Tracer with the given OpenTelemetry tracer and propagator,
+// when they are nil the global ones are used
+func New(tracer trace.Tracer, propagator propagation.TextMapPropagator) *Tracer {
+	if tracer == nil {
+		tracer = otel.Tracer("github.com/diogenes-moreira/distributed-cache")
+	}
+	if propagator == nil {
+		propagator = otel.GetTextMapPropagator()
+	}
+	return &Tracer{tracer: tracer, propagator: propagator}
+}
+
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, distributed_cache.Span) {
+	kind := trace.SpanKindInternal
+	switch name {
+	case distributed_cache.SpanSend:
+		kind = trace.SpanKindProducer
+	case distributed_cache.SpanApply:
+		kind = trace.SpanKindConsumer
+	}
+	ctx, s := t.tracer.Start(ctx, name, trace.WithSpanKind(kind))
+	return ctx, span{s}
+}
+
+func (t *Tracer) Inject(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	t.propagator.Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+	return carrier
+}
+
+func (t *Tracer) Extract(ctx context.Context, carrier map[string]string) context.Context {
+	if len(carrier) == 0 {
+		return ctx
+	}
+	return t.propagator.Extract(ctx, propagation.MapCarrier(carrier))
+}
+
+// span is a distributed_cache.Span of an OpenTelemetry span
+type span struct {
+	span trace.Span
+}
+
+func (s span) SetAttributes(attributes ...distributed_cache.Attribute) {
+	if !s.span.IsRecording() {
+		return
+	}
+	values := make([]attribute.KeyValue, 0, len(attributes))
+	for _, a := range attributes {
+		values = append(values, toKeyValue(a))
+	}
+	s.span.SetAttributes(values...)
+}
+
+func (s span) RecordError(err error) {
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+func (s span) End() {
+	s.span.End()
+}
+
+// toKeyValue converts an attribute, the durations are recorded in seconds
+func toKeyValue(a distributed_cache.Attribute) attribute.KeyValue {
+	switch v := a.Value.(type) {
+	case string:
+		return attribute.String(a.Key, v)
+	case bool:
+		return attribute.Bool(a.Key, v)
+	case int:
+		return attribute.Int(a.Key, v)
+	case int64:
+		return attribute.Int64(a.Key, v)
+	case uint64:
+		return attribute.Int64(a.Key, int64(v))
+	case float64:
+		return attribute.Float64(a.Key, v)
+	case time.Duration:
+		return attribute.Float64(a.Key, v.Seconds())
+	default:
+		return attribute.String(a.Key, fmt.Sprint(v))
+	}
+}
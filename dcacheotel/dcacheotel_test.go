@@ -0,0 +1,66 @@
+package dcacheotel
+
+import (
+	"context"
+	"errors"
+	"github.com/diogenes-moreira/distributed-cache"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"testing"
+	"time"
+)
+
+func TestTracer_Propagation(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	tracer := New(provider.Tracer("test"), propagation.TraceContext{})
+
+	ctx, write := tracer.Start(context.Background(), distributed_cache.SpanSet)
+	carrier := tracer.Inject(ctx)
+	write.SetAttributes(distributed_cache.Attribute{Key: "dcache.lock_wait", Value: time.Millisecond})
+	write.End()
+	if carrier["traceparent"] == "" {
+		t.Fatalf("Expected a traceparent in the carrier, got %v", carrier)
+	}
+
+	_, apply := tracer.Start(tracer.Extract(context.Background(), carrier), distributed_cache.SpanApply)
+	apply.RecordError(errors.New("apply failed"))
+	apply.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	if spans[1].Parent().SpanID() != spans[0].SpanContext().SpanID() || !spans[1].Parent().IsRemote() {
+		t.Errorf("Expected the apply span to be a child of the remote write")
+	}
+	if spans[1].Status().Code != codes.Error {
+		t.Errorf("Expected the error status, got %v", spans[1].Status())
+	}
+	if value := spans[0].Attributes()[0].Value.AsFloat64(); value != 0.001 {
+		t.Errorf("Expected the lock wait in seconds, got %v", value)
+	}
+}
+
+func TestTracer_Cache(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	c := distributed_cache.NewCache("otelCache", "255.255.255.255", ":12390")
+	defer c.StopListener()
+	c.Tracer = New(provider.Tracer("test"), nil)
+	c.Set("key", "value")
+	c.Get("key")
+	// the send span ends when the sender writes the message
+	time.Sleep(100 * time.Millisecond)
+	names := map[string]bool{}
+	for _, span := range recorder.Ended() {
+		names[span.Name()] = true
+	}
+	for _, name := range []string{distributed_cache.SpanSet, distributed_cache.SpanSend, distributed_cache.SpanGet} {
+		if !names[name] {
+			t.Errorf("Expected a %s span, got %v", name, names)
+		}
+	}
+}
@@ -2,4 +2,13 @@ module example
 
 go 1.22.5
 
-require github.com/diogenes-moreira/distributed-cache v0.0.0-20241111211808-5afb5ae4bc0c // indirect
+require github.com/diogenes-moreira/distributed-cache v0.0.0
+
+require (
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/vmihailenco/msgpack/v5 v5.4.1 // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace github.com/diogenes-moreira/distributed-cache => ../
@@ -1,28 +1,42 @@
 package main
 
 import (
+	"fmt"
 	distributed_cache "github.com/diogenes-moreira/distributed-cache"
+	"log"
 	"time"
 )
 
 func main() {
-	// Create a new cache with the name "cache" and the address UDP Port ":12345".
+	// Create a new cache with the name "cache", the broadcast address and the UDP Port ":12345".
 	// Name is used to identify the cache and address is used to send messages to the cache.
-	// start the listener in a goroutine. is used to listen for incoming messages.
-	cache := distributed_cache.NewCache("cache", ":12345")
+	// NewCache starts the listener in a goroutine, it is used to listen for incoming messages.
+	cache := distributed_cache.NewCache("cache", "255.255.255.255", ":12345")
 	cache.Set("key", "value")
 	value := cache.Get("key")
 	if value != nil {
-		println(value.(string))
+		fmt.Println(value.(string))
 	}
 
 	// LRU Cache Extend the Cache struct and limit the number of entries to 10.
-	lruCache := distributed_cache.NewLRUCache("lru", ":12345", 10)
+	lruCache := distributed_cache.NewLRUCache("lru", "255.255.255.255", ":12346", 10)
 	lruCache.Set("key", "value")
-	value = lruCache.Get("key")
+	fmt.Println(lruCache.Get("key"))
 
 	// LRU Cache with TTL Extend the lruCache struct and add a TTL of 10 seconds.
-	lruCacheWithTTL := distributed_cache.NewLRUCacheWithTTL("lru", ":12345", 10, time.Second*10)
+	lruCacheWithTTL := distributed_cache.NewLRUCacheWithTTL("lruTTL", "255.255.255.255", ":12347", 10, time.Second*10)
 	lruCacheWithTTL.Set("key", "value")
-	value = lruCache.Get("key")
+	fmt.Println(lruCacheWithTTL.Get("key"))
+
+	// New creates a cache with validated options and returns an error instead of exiting.
+	withOptions, err := distributed_cache.New("options",
+		distributed_cache.WithTransport("255.255.255.255", ":12348"),
+		distributed_cache.WithMaxEntries(10),
+		distributed_cache.WithTTL(time.Second*10))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer withOptions.Close()
+	withOptions.Set("key", "value")
+	fmt.Println(withOptions.Get("key"))
 }
@@ -0,0 +1,94 @@
+package distributed_cache
+
+// In this file, you can find Expire, that sets the time a single key expires
+// like the EXPIRE command of Redis, and SetWithTTL, that sets a key with its
+// time-to-live like SET EX. The deadline set by Expire is not renewed
+// when the key is read, a new Set of the key goes back to the TTL of the cache.
+// Expire is sent to the other nodes like a Delete.
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNoExpiration is returned by Expire in the cache types without a TTL
+var ErrNoExpiration = errors.New("the cache type does not expire its keys, use a TTL")
+
+// expirable is implemented by the cache types that can expire a single key
+type expirable interface {
+	expire(key string, deadline time.Time) bool
+}
+
+// Expire sets the time-to-live of a key, the key is removed at once when
+// ttl is not positive. It returns false when the key is not in the cache.
+func (c *Cache) Expire(key string, ttl time.Duration) (bool, error) {
+	c.mutex.Lock()
+	entries, ok := c.baseEntries().(expirable)
+	if !ok {
+		c.mutex.Unlock()
+		return false, ErrNoExpiration
+	}
+	exists := c.expire(entries, key, ttl)
+	c.mutex.Unlock()
+	c.sendMessageContext(context.Background(), c.expireMessage(key, ttl))
+	return exists, nil
+}
+
+// SetWithTTL is Set followed by Expire in a single write, the value is never
+// stored without its deadline. It returns ErrNoExpiration in the cache types
+// without a TTL and deletes the key when ttl is not positive
+func (c *Cache) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	if ttl <= 0 {
+		return c.Delete(key)
+	}
+	return c.set(context.Background(), key, value, ttl)
+}
+
+// expireMessage returns the message that sends an Expire to the other nodes
+func (c *Cache) expireMessage(key string, ttl time.Duration) *message {
+	message := c.newMessage(key, nil)
+	message.Op = opExpire
+	message.Setting = int64(ttl)
+	return message
+}
+
+// expire sets the deadline of a key or removes it, the mutex must be locked
+func (c *Cache) expire(entries expirable, key string, ttl time.Duration) bool {
+	if ttl > 0 {
+		deadline := time.Now().Add(ttl)
+		exists := entries.expire(key, deadline)
+		if exists && c.wal != nil {
+			c.appendRecord(record{op: recordExpire, key: key, deadline: deadline})
+		}
+		return exists
+	}
+	if _, exists := c.getEntries().load(key); !exists {
+		return false
+	}
+	c.getEntries().remove(key)
+	return true
+}
+
+// expire sets the deadline of a key that is not renewed when it is read,
+// the mutex must be locked
+func (c *LRUCacheWithTTL) expire(key string, deadline time.Time) bool {
+	if _, exists := c.storage[key]; !exists {
+		return false
+	}
+	if c.pinned == nil {
+		c.pinned = make(map[string]bool)
+	}
+	c.ttlMap[key] = deadline
+	c.pinned[key] = true
+	return true
+}
+
+// applyExpire applies an Expire received from another node
+func (c *Cache) applyExpire(message *message) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if entries, ok := c.baseEntries().(expirable); ok {
+		c.expire(entries, message.Key, time.Duration(message.Setting))
+	}
+}
@@ -0,0 +1,82 @@
+package distributed_cache
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func TestCache_Expire(t *testing.T) {
+	c := NewLRUCacheWithTTL("expireCache", "255.255.255.255", ":12400", 10, time.Hour)
+	defer c.StopListener()
+	c.Set("key1", "value1")
+	c.Set("key2", "value2")
+
+	if exists, err := c.Expire("key1", 50*time.Millisecond); !exists || err != nil {
+		t.Fatalf("Expected key1 to exist, got %v and %v", exists, err)
+	}
+	if exists, _ := c.Expire("missing", time.Minute); exists {
+		t.Errorf("Expected missing not to exist")
+	}
+	// the deadline set by Expire is not renewed by Get
+	time.Sleep(30 * time.Millisecond)
+	if val := c.Get("key1"); val != "value1" {
+		t.Errorf("Expected value1, got %v", val)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if val := c.Get("key1"); val != nil {
+		t.Errorf("Expected key1 to be expired, got %v", val)
+	}
+
+	if exists, _ := c.Expire("key2", 0); !exists {
+		t.Errorf("Expected key2 to exist")
+	}
+	if val := c.Get("key2"); val != nil {
+		t.Errorf("Expected key2 to be removed, got %v", val)
+	}
+
+	plain := NewCache("expireCache", "255.255.255.255", ":12401")
+	defer plain.StopListener()
+	if _, err := plain.Expire("key", time.Minute); !errors.Is(err, ErrNoExpiration) {
+		t.Errorf("Expected ErrNoExpiration, got %v", err)
+	}
+}
+
+func TestCache_ExpireRemote(t *testing.T) {
+	c := NewLRUCacheWithTTL("expireCache", "255.255.255.255", ":12402", 10, time.Hour)
+	defer c.StopListener()
+	c.Set("key", "value")
+	c.handleMessage(&message{CacheName: "expireCache", Node: uuid.New(), Key: "key", Op: opExpire,
+		Setting: int64(time.Minute)})
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if deadline := c.ttlMap["key"]; !c.pinned["key"] || time.Until(deadline) > time.Minute {
+		t.Errorf("Expected the deadline of the expire, got %v", deadline)
+	}
+}
+
+func TestCache_SetWithTTL(t *testing.T) {
+	c := NewLRUCacheWithTTL("expireCache", "255.255.255.255", ":12530", 10, time.Hour)
+	defer c.StopListener()
+	if err := c.SetWithTTL("key", "value", 50*time.Millisecond); err != nil {
+		t.Fatalf("SetWithTTL() error = %v", err)
+	}
+	if val := c.Get("key"); val != "value" {
+		t.Errorf("Expected value, got %v", val)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if val := c.Get("key"); val != nil {
+		t.Errorf("Expected key to be expired, got %v", val)
+	}
+
+	plain := NewCache("expireCache", "255.255.255.255", ":12531")
+	defer plain.StopListener()
+	if err := plain.SetWithTTL("key", "value", time.Minute); !errors.Is(err, ErrNoExpiration) {
+		t.Errorf("Expected ErrNoExpiration, got %v", err)
+	}
+	if val := plain.Get("key"); val != nil {
+		t.Errorf("Expected the key not to be set, got %v", val)
+	}
+}
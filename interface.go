@@ -0,0 +1,27 @@
+package distributed_cache
+
+// In this file, you can find DistributedCache, the interface implemented by
+// every cache type, to write code and mocks that work with any of them.
+// The cachetest package has the tests that a cache type must pass.
+
+// DistributedCache is implemented by every cache type
+type DistributedCache interface {
+	// Get gets a value, nil when the key is not found and cannot be filled
+	Get(key string) interface{}
+	// Set sets a value and sends it to the other nodes
+	Set(key string, value interface{}) error
+	// Delete deletes a key and sends the delete to the other nodes
+	Delete(key string) error
+	// Clean removes every key and sends the clean to the other nodes
+	Clean()
+	// Close stops the cache after sending the pending messages
+	Close() error
+	// Stats returns the statistics of the cache
+	Stats() Stats
+}
+
+var (
+	_ DistributedCache = (*Cache)(nil)
+	_ DistributedCache = (*LRUCache)(nil)
+	_ DistributedCache = (*LRUCacheWithTTL)(nil)
+)
@@ -0,0 +1,46 @@
+package distributed_cache_test
+
+import (
+	distributed_cache "github.com/diogenes-moreira/distributed-cache"
+	"github.com/diogenes-moreira/distributed-cache/cachetest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// conformancePort is the last port used by the caches of the conformance tests
+var conformancePort atomic.Int32
+
+func nextAddress() string {
+	conformancePort.CompareAndSwap(0, 12410)
+	return ":" + strconv.Itoa(int(conformancePort.Add(1)))
+}
+
+func TestConformance(t *testing.T) {
+	cacheTypes := map[string]func(t *testing.T) distributed_cache.DistributedCache{
+		"Cache": func(t *testing.T) distributed_cache.DistributedCache {
+			return distributed_cache.NewCache("conformanceCache", "255.255.255.255", nextAddress())
+		},
+		"LRUCache": func(t *testing.T) distributed_cache.DistributedCache {
+			return distributed_cache.NewLRUCache("conformanceCache", "255.255.255.255", nextAddress(), 100)
+		},
+		"LRUCacheWithTTL": func(t *testing.T) distributed_cache.DistributedCache {
+			return distributed_cache.NewLRUCacheWithTTL("conformanceCache", "255.255.255.255", nextAddress(), 100,
+				time.Minute)
+		},
+		"New": func(t *testing.T) distributed_cache.DistributedCache {
+			c, err := distributed_cache.New("conformanceCache", distributed_cache.WithTransport("", nextAddress()),
+				distributed_cache.WithMaxEntries(100), distributed_cache.WithTTL(time.Minute))
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			return c
+		},
+	}
+	for name, newCache := range cacheTypes {
+		t.Run(name, func(t *testing.T) {
+			cachetest.Run(t, newCache)
+		})
+	}
+}
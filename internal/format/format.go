@@ -0,0 +1,21 @@
+// Package format formats the values of the caches as text for the commands.
+package format
+
+// In this file, you can find Value, that formats a value as text, the
+// strings as they are and the other values set by the Go nodes as JSON.
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Value formats a value, the values that are not strings as JSON
+func Value(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	if data, err := json.Marshal(value); err == nil {
+		return string(data)
+	}
+	return fmt.Sprintf("%v", value)
+}
@@ -0,0 +1,18 @@
+package format
+
+import "testing"
+
+func TestValue(t *testing.T) {
+	tests := map[string]interface{}{
+		"value":        "value",
+		"[1,2]":        []int{1, 2},
+		`{"name":"a"}`: map[string]string{"name": "a"},
+		"42":           42,
+		"<nil>":        (func())(nil),
+	}
+	for expected, value := range tests {
+		if formatted := Value(value); formatted != expected {
+			t.Errorf("Expected %q, got %q", expected, formatted)
+		}
+	}
+}
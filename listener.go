@@ -3,9 +3,9 @@ package distributed_cache
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
-	"log"
 	"net"
 	"os"
 	"time"
@@ -28,51 +28,95 @@ type uDPConnInterface interface {
 }
 
 type iCache interface {
-	set(key string, value interface{})
-	clean()
+	handleMessage(message *message)
 	getAddress() string
 	getName() string
 	getNode() uuid.UUID
+	getCodec() Codec
+	getCounters() *counters
+	getKey() []byte
+	getReplays() *replayGuard
+	logError(msg string, err error, args ...any)
 }
 
 // startListener starts the listener to receive messages from the other nodes
 func startListener(c iCache, ctx context.Context) {
-	conn := createListener(c.getAddress())
+	serve(c, ctx, createListener(c.getAddress()))
+}
+
+// serve receives the messages of the other nodes from a connection
+// until the context is done
+func serve(c iCache, ctx context.Context, conn uDPConnInterface) {
 	for {
 		select {
 		case <-ctx.Done():
-			err := conn.Close()
-			if err != nil {
-				log.Println(err)
-				return
+			if err := conn.Close(); err != nil {
+				c.logError("closing the listener failed", err)
 			}
 			return
 		default:
-			message, err := handleClient(conn)
+			message, err := receive(conn, c.getKey(), c.getReplays())
 			if err != nil {
-				log.Println(err)
+				var netErr net.Error
+				if errors.As(err, &netErr) {
+					c.logError("read failed", err)
+				} else if errors.Is(err, ErrUnauthenticated) {
+					c.getCounters().decodeErrors.Add(1)
+					c.logError("authentication failed", err)
+				} else {
+					c.getCounters().decodeErrors.Add(1)
+					c.logError("decoding envelope failed", err)
+				}
 				continue
 			}
-			if message.CacheName != c.getName() || message.Node == c.getNode() {
+			if message.CacheName != c.getName() {
+				c.getCounters().ignored.Add(uint64(len(message.messages())))
 				continue
 			}
-			if message.isCleanMessage() {
-				c.clean()
+			if message.Node == c.getNode() {
 				continue
-			} else {
-				c.set(message.Key, message.Value)
+			}
+			for _, message := range message.messages() {
+				if message.Target != uuid.Nil && message.Target != c.getNode() {
+					continue
+				}
+				if err := message.decodeValue(c.getCodec()); err != nil {
+					c.getCounters().decodeErrors.Add(1)
+					c.logError("decoding value failed", err, "key", message.Key, "op", message.Op.String(),
+						"peer", message.from.String())
+					continue
+				}
+				c.getCounters().received.Add(1)
+				c.handleMessage(message)
 			}
 		}
 	}
 }
 
+// handleMessage applies a message received from another node
+func (c *Cache) handleMessage(message *message) {
+	c.notify(message)
+	switch {
+	case message.Op == opHeartbeat:
+		c.addMember(message)
+	case message.Op == opGet:
+		go c.replyGet(message)
+	case message.Op == opGetReply:
+		c.deliverReply(message)
+	case message.Op == opResize, message.Op == opSetTTL:
+		c.applyTraced(message, func() { c.applySetting(message) })
+	case message.Op == opExpire:
+		c.applyTraced(message, func() { c.applyExpire(message) })
+	case message.isCleanMessage():
+		c.applyTraced(message, c.clean)
+	default:
+		c.applyTraced(message, func() { c.applyRemote(message) })
+	}
+}
+
 // createListener creates a connection to listen for messages
 func createListener(address string) *net.UDPConn {
-	udpAddr, err := net.ResolveUDPAddr("udp", address)
-	if err != nil {
-		log.Fatal(err)
-	}
-	conn, err := net.ListenUDP("udp", udpAddr)
+	conn, err := listen(address)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
@@ -80,20 +124,50 @@ func createListener(address string) *net.UDPConn {
 	return conn
 }
 
-// handleClient handles the client messages
+// listen creates a connection to listen for messages
+func listen(address string) (*net.UDPConn, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	return net.ListenUDP("udp", udpAddr)
+}
+
+// handleClient handles the client messages,
+// the value is decoded later with the codec of the cache
 func handleClient(conn uDPConnInterface) (*message, error) {
-	buffer := make([]byte, 1024)
-	n, _, err := conn.ReadFromUDP(buffer)
+	return receive(conn, nil, nil)
+}
+
+// receive reads a datagram and decodes its envelope, the signature is verified
+// and removed when key is set and the replays are dropped with the guard
+func receive(conn uDPConnInterface, key []byte, replays *replayGuard) (*message, error) {
+	buffer := make([]byte, datagramSize)
+	n, addr, err := conn.ReadFromUDP(buffer)
 	if err != nil {
-		log.Println(err)
 		return nil, err
 	}
+	data := buffer[:n]
+	if key != nil {
+		var node uuid.UUID
+		var sent int64
+		if data, node, sent, err = verify(key, data); err != nil {
+			return nil, &peerError{addr: addr, err: err}
+		}
+		if err = replays.check(node, sent, time.Now()); err != nil {
+			return nil, &peerError{addr: addr, err: err}
+		}
+	}
 
 	var message message
-	network := bytes.NewBuffer(buffer[:n])
-	if err := message.fromUDP(network.Bytes()); err != nil {
-		log.Println(err)
-		return nil, err
+	network := bytes.NewBuffer(data)
+	if err := message.decodeEnvelope(network.Bytes()); err != nil {
+		return nil, &peerError{addr: addr, err: err}
+	}
+	if addr != nil {
+		for _, received := range message.messages() {
+			received.from = addr.IP
+		}
 	}
 	return &message, nil
 }
@@ -44,6 +44,9 @@ func TestHandleClient(t *testing.T) {
 	if err != nil {
 		t.Fatalf("handleClient() error = %v", err)
 	}
+	if err := receivedMessage.decodeValue(nil); err != nil {
+		t.Fatalf("decodeValue() error = %v", err)
+	}
 
 	if receivedMessage.CacheName != message.CacheName || receivedMessage.Key != message.Key ||
 		receivedMessage.Value != message.Value {
@@ -0,0 +1,104 @@
+package distributed_cache
+
+// In this file, you can find the logging of the caches.
+// The errors are logged with the Logger of the cache, with the name of the
+// cache and the node and the fields of each error (key, op, peer...).
+// The same error is logged at most once every LogInterval, with the number of
+// times it was suppressed. Without a Logger nothing is logged.
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultLogInterval is the minimum time between two logs of the same error
+const DefaultLogInterval = time.Second
+
+// peerError is an error of a datagram received from a peer
+type peerError struct {
+	addr net.Addr
+	err  error
+}
+
+func (e *peerError) Error() string {
+	return e.err.Error()
+}
+
+func (e *peerError) Unwrap() error {
+	return e.err
+}
+
+// logLimiter rate-limits the logs of the same message
+type logLimiter struct {
+	mutex sync.Mutex
+	last  map[string]*logState
+}
+
+type logState struct {
+	logged     time.Time
+	suppressed int
+}
+
+// allow returns true when a message can be logged and the number of times
+// it was suppressed since it was logged
+func (l *logLimiter) allow(msg string, interval time.Duration) (int, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.last == nil {
+		l.last = make(map[string]*logState)
+	}
+	state, exists := l.last[msg]
+	now := time.Now()
+	if !exists {
+		l.last[msg] = &logState{logged: now}
+		return 0, true
+	}
+	if now.Sub(state.logged) < interval {
+		state.suppressed++
+		return 0, false
+	}
+	suppressed := state.suppressed
+	state.logged, state.suppressed = now, 0
+	return suppressed, true
+}
+
+// logError logs an error with the fields in args as key-value pairs
+func (c *Cache) logError(msg string, err error, args ...any) {
+	c.log(slog.LevelError, msg, err, args...)
+}
+
+// logWarn logs an error that the cache recovered from
+func (c *Cache) logWarn(msg string, err error, args ...any) {
+	c.log(slog.LevelWarn, msg, err, args...)
+}
+
+func (c *Cache) log(level slog.Level, msg string, err error, args ...any) {
+	logger := c.Logger
+	if logger == nil || !logger.Enabled(context.Background(), level) {
+		return
+	}
+	interval := c.LogInterval
+	if interval <= 0 {
+		interval = DefaultLogInterval
+	}
+	suppressed, ok := c.logLimiter.allow(msg, interval)
+	if !ok {
+		return
+	}
+	var peer *peerError
+	if errors.As(err, &peer) && peer.addr != nil {
+		args = append(args, "peer", peer.addr.String())
+	}
+	args = append(args, "cache", c.Name, "node", c.node.String())
+	if err != nil {
+		args = append(args, "error", err)
+	}
+	if suppressed > 0 {
+		args = append(args, "suppressed", suppressed)
+	}
+	logger.Log(context.Background(), level, msg, args...)
+}
@@ -0,0 +1,59 @@
+package distributed_cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCache_LoggerRateLimit(t *testing.T) {
+	var buffer bytes.Buffer
+	c := NewCache("loggingCache", "255.255.255.255", ":12383")
+	defer c.StopListener()
+	c.Logger = slog.New(slog.NewJSONHandler(&buffer, nil))
+	c.LogInterval = 50 * time.Millisecond
+	c.Filler = func(key string) (interface{}, error) {
+		return nil, errors.New("fill failed")
+	}
+	for i := 0; i < 5; i++ {
+		c.Get("key")
+	}
+	time.Sleep(60 * time.Millisecond)
+	c.Get("key")
+
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 logs, got %d:\n%s", len(lines), buffer.String())
+	}
+	var first, second map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &second); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if first["msg"] != "filler failed" || first["key"] != "key" || first["cache"] != "loggingCache" ||
+		first["node"] != c.node.String() || first["error"] != "fill failed" || first["level"] != "ERROR" {
+		t.Errorf("Expected the fields of the error, got %v", first)
+	}
+	if second["suppressed"] != float64(4) {
+		t.Errorf("Expected 4 suppressed logs, got %v", second["suppressed"])
+	}
+}
+
+func TestCache_LoggerPeer(t *testing.T) {
+	var buffer bytes.Buffer
+	c := NewCache("loggingCache", "255.255.255.255", ":12384")
+	defer c.StopListener()
+	c.Logger = slog.New(slog.NewTextHandler(&buffer, nil))
+	addr := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 12384}
+	c.logError("decoding envelope failed", &peerError{addr: addr, err: errors.New("unexpected EOF")})
+	if !strings.Contains(buffer.String(), "peer=10.0.0.1:12384") {
+		t.Errorf("Expected the peer address, got %s", buffer.String())
+	}
+}
@@ -16,32 +16,24 @@ import (
 // that uses the Least Recently Used algorithm to evict entries
 type LRUCache struct {
 	Cache
-	MaxEntries int
+	MaxEntries int // Maximum number of entries, unbounded when 0
 	queue      []string
 }
 
-func (c *LRUCache) clean() {
-	c.mutex.Lock()
-	if c.RemoveHook != nil {
-		for key, value := range c.storage {
-			go c.RemoveHook(key, value)
-		}
-	}
-	c.queue = make([]string, 0)
-	c.storage = make(map[string]interface{})
-	c.mutex.Unlock()
-}
-
-func (c *LRUCache) set(key string, value interface{}) {
-	c.mutex.Lock()
+// store stores the value of a key and evicts the least recently used key
+// when the cache is full, the mutex must be locked
+func (c *LRUCache) store(key string, value interface{}) {
 	_, exists := c.storage[key]
 	if !exists {
 		c.queue = append(c.queue, key)
-		if len(c.queue) > c.MaxEntries {
+		if c.MaxEntries > 0 && len(c.queue) > c.MaxEntries {
 			if c.RemoveHook != nil {
 				go c.RemoveHook(c.queue[0], c.storage[c.queue[0]])
 			}
-			delete(c.storage, c.queue[0])
+			c.evict(c.queue[0])
+			c.deleteStorage(c.queue[0])
+			delete(c.meta, c.queue[0])
+			c.counters.evictedCapacity.Add(1)
 			c.queue = c.queue[1:]
 		}
 	} else {
@@ -49,46 +41,33 @@ func (c *LRUCache) set(key string, value interface{}) {
 		c.queue = append(c.queue[:index], c.queue[index+1:]...)
 		c.queue = append(c.queue, key)
 	}
-	c.storage[key] = value
-	c.mutex.Unlock()
+	c.putStorage(key, value)
 }
 
-func (c *LRUCache) delete(key string) {
-	c.mutex.Lock()
+// remove removes a key, the mutex must be locked
+func (c *LRUCache) remove(key string) {
 	index := slices.Index(c.queue, key)
 	if index != -1 {
 		if c.RemoveHook != nil {
 			go c.RemoveHook(key, c.storage[key])
 		}
 		c.queue = append(c.queue[:index], c.queue[index+1:]...)
-		delete(c.storage, key)
+		c.deleteStorage(key)
+		delete(c.meta, key)
 	}
-	c.mutex.Unlock()
 }
 
-func (c *LRUCache) Set(key string, value interface{}) {
-	if value == nil {
-		c.Delete(key)
-		return
-	}
-	c.sendSet(key, value)
-	c.set(key, value)
-}
-
-// Delete deletes a value from the cache
-// and sends the delete message to the other nodes
-func (c *LRUCache) Delete(key string) {
-	c.sendDelete(key)
-	c.delete(key)
-}
-
-// Clean deletes all values from the cache
-// and sends the sendClean message to the other nodes
-func (c *LRUCache) Clean() {
-	c.clean()
-	c.sendClean()
+// removeAll removes all the keys in LRU order, the mutex must be locked
+func (c *LRUCache) removeAll() {
+	c.runRemoveHooks(c.queue, c.storage)
+	c.queue = make([]string, 0)
+	c.resetStorage()
+	c.meta = nil
+	c.lost, c.lostOrder = nil, nil
 }
 
+// NewLRUCache creates a new LRUCache with the given name, address and maxEntries.
+// It also starts a listener to receive messages from other nodes
 func NewLRUCache(name, broadcast, address string, maxEntries int) *LRUCache {
 	ctx, cancel := context.WithCancel(context.Background())
 	c := &LRUCache{
@@ -104,6 +83,8 @@ func NewLRUCache(name, broadcast, address string, maxEntries int) *LRUCache {
 		MaxEntries: maxEntries,
 		queue:      make([]string, 0),
 	}
+	c.entries = c
 	go startListener(c, ctx)
+	go c.startHeartbeat(ctx)
 	return c
 }
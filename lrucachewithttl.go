@@ -8,8 +8,6 @@ package distributed_cache
 import (
 	"context"
 	"github.com/google/uuid"
-	"log"
-	"slices"
 	"sync"
 	"time"
 )
@@ -24,119 +22,74 @@ type LRUCacheWithTTL struct {
 	LRUCache
 	TTL    time.Duration
 	ttlMap map[string]time.Time
+	pinned map[string]bool // keys with a deadline set by Expire, it is not renewed
 }
 
-func (c *LRUCacheWithTTL) clean() {
-	c.mutex.Lock()
-	if c.RemoveHook != nil {
-		for key, value := range c.storage {
-			go c.RemoveHook(key, value)
-		}
+// load returns the value of a key that has not expired and renews its TTL,
+// the mutex must be locked
+func (c *LRUCacheWithTTL) load(key string) (interface{}, bool) {
+	value, exists := c.storage[key]
+	if !exists {
+		return nil, false
 	}
-	c.queue = make([]string, 0)
-	c.storage = make(map[string]interface{})
-	c.ttlMap = make(map[string]time.Time)
-	c.mutex.Unlock()
+	if time.Now().After(c.ttlMap[key]) {
+		c.removeExpiredKey(key)
+		return nil, false
+	}
+	if !c.pinned[key] {
+		c.ttlMap[key] = time.Now().Add(c.TTL)
+	}
+	return value, true
 }
 
-func (c *LRUCacheWithTTL) set(key string, value interface{}) {
-	c.evict()
-	c.mutex.Lock()
+// store stores the value of a key with a new TTL
+// after evicting the expired entries, the mutex must be locked
+func (c *LRUCacheWithTTL) store(key string, value interface{}) {
+	c.removeExpired()
 	_, exists := c.storage[key]
-	if !exists {
-		c.queue = append(c.queue, key)
-		if len(c.queue) > c.MaxEntries {
-			if c.RemoveHook != nil {
-				go c.RemoveHook(c.queue[0], c.storage[c.queue[0]])
-			}
-			delete(c.storage, c.queue[0])
-			c.queue = c.queue[1:]
-		}
-	} else {
-		index := slices.Index(c.queue, key)
-		c.queue = append(c.queue[:index], c.queue[index+1:]...)
-		c.queue = append(c.queue, key)
+	if !exists && c.MaxEntries > 0 && len(c.queue) > 0 && len(c.queue) >= c.MaxEntries {
+		// the least recently used key is evicted by LRUCache.store
+		delete(c.ttlMap, c.queue[0])
+		delete(c.pinned, c.queue[0])
 	}
+	c.LRUCache.store(key, value)
 	c.ttlMap[key] = time.Now().Add(c.TTL)
-	c.storage[key] = value
-	c.mutex.Unlock()
+	delete(c.pinned, key)
 }
 
-func (c *LRUCacheWithTTL) delete(key string) {
-	c.mutex.Lock()
-	index := slices.Index(c.queue, key)
-	if index != -1 {
-		if c.RemoveHook != nil {
-			go c.RemoveHook(key, c.storage[key])
-		}
-		c.queue = append(c.queue[:index], c.queue[index+1:]...)
-		delete(c.storage, key)
-		delete(c.ttlMap, key)
-	}
-	c.mutex.Unlock()
+// remove removes a key, the mutex must be locked
+func (c *LRUCacheWithTTL) remove(key string) {
+	c.LRUCache.remove(key)
+	delete(c.ttlMap, key)
+	delete(c.pinned, key)
 }
 
-// evict deletes entries that have expired
-func (c *LRUCacheWithTTL) evict() {
-	c.mutex.Lock()
-	for key, ttl := range c.ttlMap {
-		if time.Now().After(ttl) {
-			index := slices.Index(c.queue, key)
-			if c.RemoveHook != nil {
-				go c.RemoveHook(key, c.storage[key])
-			}
-			c.queue = append(c.queue[:index], c.queue[index+1:]...)
-			delete(c.storage, key)
-			delete(c.ttlMap, key)
-		}
-	}
-	c.mutex.Unlock()
-}
-
-func (c *LRUCacheWithTTL) Set(key string, value interface{}) {
-	if value == nil {
-		c.Delete(key)
-		return
-	}
-	c.sendSet(key, value)
-	c.set(key, value)
+// removeAll removes all the keys, the mutex must be locked
+func (c *LRUCacheWithTTL) removeAll() {
+	c.LRUCache.removeAll()
+	c.ttlMap = make(map[string]time.Time)
+	c.pinned = nil
 }
 
-// Get gets a value from the cache
-func (c *LRUCacheWithTTL) Get(key string) interface{} {
-	c.evict()
-	c.mutex.Lock()
-	out, exists := c.storage[key]
-	if exists {
-		c.ttlMap[key] = time.Now().Add(c.TTL)
-		c.mutex.Unlock()
-	} else {
-		c.mutex.Unlock()
-		var err error
-		if c.Filler != nil {
-			out, err = c.Filler(key)
-			if err != nil {
-				log.Println(err)
-			}
-			c.Set(key, out)
+// removeExpired removes the entries that have expired, the mutex must be locked
+func (c *LRUCacheWithTTL) removeExpired() {
+	now := time.Now()
+	for key, ttl := range c.ttlMap {
+		if now.After(ttl) {
+			c.removeExpiredKey(key)
 		}
 	}
-
-	return out
 }
 
-// Delete deletes a value from the cache
-// and sends the delete message to the other nodes
-func (c *LRUCacheWithTTL) Delete(key string) {
-	c.sendDelete(key)
-	c.delete(key)
-}
-
-// Clean deletes all values from the cache
-// and sends the sendClean message to the other nodes
-func (c *LRUCacheWithTTL) Clean() {
-	c.clean()
-	c.sendClean()
+// removeExpiredKey removes a key that has expired and appends the removal
+// to the log when it is enabled, the mutex must be locked
+func (c *LRUCacheWithTTL) removeExpiredKey(key string) {
+	if c.wal != nil {
+		c.appendRecord(record{op: recordDelete, key: key})
+	}
+	c.evict(key)
+	c.remove(key)
+	c.counters.evictedExpired.Add(1)
 }
 
 // NewLRUCacheWithTTL creates a new LRUCacheWithTTL with the given name,
@@ -167,7 +120,9 @@ func NewLRUCacheWithTTL(name, broadcast, address string, maxEntries int, ttl tim
 		TTL:    ttl,
 		ttlMap: make(map[string]time.Time),
 	}
+	c.entries = c
 
 	go startListener(c, ctx)
+	go c.startHeartbeat(ctx)
 	return c
 }
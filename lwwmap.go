@@ -0,0 +1,108 @@
+package distributed_cache
+
+// In this file, you can find the LWWMap CRDT and the methods MapPut, MapDelete
+// and MapGet. Each field of the map is a last-write-wins register, so changes to
+// different fields made in different nodes are all kept, and concurrent changes
+// to the same field are resolved in the same way in every node.
+// The values are encoded with the codec of the cache.
+
+import (
+	"context"
+	"time"
+)
+
+// LWWMap is a map of last-write-wins fields
+type LWWMap struct {
+	Fields map[string]LWWField
+}
+
+// LWWField is a field of a LWWMap, Time and Node order the writes
+type LWWField struct {
+	Value   []byte // encoded with the codec of the cache
+	Time    int64  // unix nanoseconds of the write
+	Node    string // node that wrote the field
+	Deleted bool
+}
+
+// newerThan returns true when the field was written after the other one
+func (f LWWField) newerThan(other LWWField) bool {
+	if f.Time != other.Time {
+		return f.Time > other.Time
+	}
+	return f.Node > other.Node
+}
+
+// Merge returns a map with the newest write of each field of both maps
+func (m *LWWMap) Merge(other CRDT) CRDT {
+	merged := &LWWMap{Fields: make(map[string]LWWField)}
+	otherMap, _ := other.(*LWWMap)
+	for _, lwwMap := range []*LWWMap{m, otherMap} {
+		if lwwMap == nil {
+			continue
+		}
+		for name, field := range lwwMap.Fields {
+			current, exists := merged.Fields[name]
+			if !exists || field.newerThan(current) {
+				merged.Fields[name] = field
+			}
+		}
+	}
+	return merged
+}
+
+// writeDelta returns the delta that writes a field,
+// the time is after the current write of the field even if the clocks drift
+func (m *LWWMap) writeDelta(node, name string, value []byte, deleted bool) *LWWMap {
+	now := time.Now().UnixNano()
+	if m != nil {
+		if current, exists := m.Fields[name]; exists && current.Time >= now {
+			now = current.Time + 1
+		}
+	}
+	field := LWWField{Value: value, Time: now, Node: node, Deleted: deleted}
+	return &LWWMap{Fields: map[string]LWWField{name: field}}
+}
+
+// MapPut sets a field of the map of a key and sends the change to the other nodes.
+// A value of another type is replaced
+func (c *Cache) MapPut(key, field string, value interface{}) error {
+	data, err := c.getCodec().Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = c.mutate(context.Background(), key, func(current interface{}) CRDT {
+		lwwMap, _ := current.(*LWWMap)
+		return lwwMap.writeDelta(c.node.String(), field, data, false)
+	})
+	return err
+}
+
+// MapDelete deletes a field of the map of a key and sends the change to the other nodes
+func (c *Cache) MapDelete(key, field string) {
+	c.mutate(context.Background(), key, func(current interface{}) CRDT {
+		lwwMap, _ := current.(*LWWMap)
+		return lwwMap.writeDelta(c.node.String(), field, nil, true)
+	})
+}
+
+// MapGet returns the fields of the map of a key decoded with the codec of the cache,
+// empty when it is not a map
+func (c *Cache) MapGet(key string) (map[string]interface{}, error) {
+	lwwMap, _ := c.readCRDT(key).(*LWWMap)
+	out := make(map[string]interface{})
+	if lwwMap == nil {
+		return out, nil
+	}
+	codec := c.getCodec()
+	for name, field := range lwwMap.Fields {
+		if field.Deleted {
+			continue
+		}
+		value, err := codec.Unmarshal(field.Value)
+		if err != nil {
+			return nil, err
+		}
+		out[name] = value
+	}
+	return out, nil
+}
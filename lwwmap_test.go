@@ -0,0 +1,46 @@
+package distributed_cache
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCache_MapPut(t *testing.T) {
+	c := NewCache("mapCache", "255.255.255.255", ":12516")
+	defer c.StopListener()
+	if err := c.MapPut("prefs", "theme", "dark"); err != nil {
+		t.Fatalf("MapPut() error = %v", err)
+	}
+	if err := c.MapPut("prefs", "lang", "es"); err != nil {
+		t.Fatalf("MapPut() error = %v", err)
+	}
+	c.MapDelete("prefs", "lang")
+
+	values, err := c.MapGet("prefs")
+	if err != nil {
+		t.Fatalf("MapGet() error = %v", err)
+	}
+	if !reflect.DeepEqual(values, map[string]interface{}{"theme": "dark"}) {
+		t.Errorf("Expected map[theme:dark], got %v", values)
+	}
+}
+
+func TestLWWMap_MergeKeepsNewestWrite(t *testing.T) {
+	a := &LWWMap{Fields: map[string]LWWField{
+		"theme": {Value: []byte("dark"), Time: 2, Node: "a"},
+		"lang":  {Value: []byte("es"), Time: 1, Node: "a"},
+	}}
+	b := &LWWMap{Fields: map[string]LWWField{
+		"theme": {Value: []byte("light"), Time: 1, Node: "b"},
+		"lang":  {Value: []byte("en"), Time: 1, Node: "b"},
+	}}
+
+	ab := a.Merge(b).(*LWWMap)
+	ba := b.Merge(a).(*LWWMap)
+	if !reflect.DeepEqual(ab, ba) {
+		t.Errorf("Expected the same result in both orders, got %v and %v", ab, ba)
+	}
+	if string(ab.Fields["theme"].Value) != "dark" || string(ab.Fields["lang"].Value) != "en" {
+		t.Errorf("Unexpected merge %v", ab)
+	}
+}
@@ -0,0 +1,118 @@
+package distributed_cache
+
+// In this file, you can find the membership of the cache cluster.
+// Every node of a partitioned cache sends a heartbeat each HeartbeatInterval,
+// the nodes that do not send heartbeats for memberTimeout intervals are removed
+// from the members. The members are used to build the hash ring of the
+// partitioned mode, the other caches do not need them and send no heartbeats.
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"net"
+	"slices"
+	"time"
+)
+
+// DefaultHeartbeatInterval is the time between heartbeats
+// when the cache does not set HeartbeatInterval
+const DefaultHeartbeatInterval = time.Second
+
+// memberTimeout is the number of heartbeat intervals without heartbeats
+// after which a member is removed
+const memberTimeout = 3
+
+// Member is a node of the cache cluster
+type Member struct {
+	Node     uuid.UUID
+	Address  string // address of the listener of the node
+	LastSeen time.Time
+	Local    bool // true for this node
+}
+
+// Members returns the members of the cluster known by this node, including itself,
+// only the nodes of partitioned caches send heartbeats and are known
+func (c *Cache) Members() []Member {
+	c.membersMutex.RLock()
+	members := make([]Member, 0, len(c.members)+1)
+	for _, member := range c.members {
+		members = append(members, member)
+	}
+	c.membersMutex.RUnlock()
+	members = append(members, Member{Node: c.node, Address: c.Address, LastSeen: time.Now(), Local: true})
+	slices.SortFunc(members, func(a, b Member) int {
+		return slices.Compare(a.Node[:], b.Node[:])
+	})
+	return members
+}
+
+// startHeartbeat sends the heartbeats of the node until the context is done
+func (c *Cache) startHeartbeat(ctx context.Context) {
+	for {
+		// the heartbeats are not traced, the observers do not send them
+		if c.Partitioned && !c.Observer {
+			c.getSender().enqueue(ctx, &message{CacheName: c.Name, Node: c.node, Op: opHeartbeat})
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(c.getHeartbeatInterval()):
+			c.expireMembers()
+		}
+	}
+}
+
+// addMember adds or refreshes the member that sent a heartbeat
+func (c *Cache) addMember(message *message) {
+	member := Member{Node: message.Node, LastSeen: time.Now()}
+	if message.from != nil {
+		_, port, err := net.SplitHostPort(c.Address)
+		if err == nil {
+			member.Address = net.JoinHostPort(message.from.String(), port)
+		}
+	}
+	c.membersMutex.Lock()
+	defer c.membersMutex.Unlock()
+	if c.members == nil {
+		c.members = make(map[uuid.UUID]Member)
+	}
+	_, known := c.members[member.Node]
+	c.members[member.Node] = member
+	if !known {
+		c.buildRing()
+	}
+}
+
+// expireMembers removes the members that stopped sending heartbeats
+func (c *Cache) expireMembers() {
+	deadline := time.Now().Add(-memberTimeout * c.getHeartbeatInterval())
+	c.membersMutex.Lock()
+	defer c.membersMutex.Unlock()
+	changed := false
+	for node, member := range c.members {
+		if member.LastSeen.Before(deadline) {
+			delete(c.members, node)
+			changed = true
+		}
+	}
+	if changed {
+		c.buildRing()
+	}
+}
+
+// buildRing builds the hash ring with the members and this node,
+// the membersMutex must be locked
+func (c *Cache) buildRing() {
+	nodes := []uuid.UUID{c.node}
+	for node := range c.members {
+		nodes = append(nodes, node)
+	}
+	c.ring = newHashRing(nodes, c.VirtualNodes)
+}
+
+func (c *Cache) getHeartbeatInterval() time.Duration {
+	if c.HeartbeatInterval <= 0 {
+		return DefaultHeartbeatInterval
+	}
+	return c.HeartbeatInterval
+}
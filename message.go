@@ -6,42 +6,244 @@ package distributed_cache
 import (
 	"bytes"
 	"encoding/gob"
+	"fmt"
 	"github.com/google/uuid"
+	"net"
 )
 
+// operation is the kind of message,
+// opWrite is used by the nodes that do not send operations
+type operation uint8
+
+const (
+	opWrite      operation = iota // set, delete when Value is nil, or clean with cleanMessageKey
+	opHeartbeat                   // announces the node to the other members
+	opGet                         // asks the Target node for the value of Key
+	opGetReply                    // answers an opGet with the same Request
+	opInvalidate                  // asks the nodes to drop Key if their copy is older than Version
+	opResize                      // sets MaxEntries to Setting
+	opSetTTL                      // sets TTL to Setting nanoseconds
+	opExpire                      // sets the time-to-live of Key to Setting nanoseconds
+)
+
+func (o operation) String() string {
+	switch o {
+	case opWrite:
+		return "write"
+	case opHeartbeat:
+		return "heartbeat"
+	case opGet:
+		return "get"
+	case opGetReply:
+		return "get-reply"
+	case opInvalidate:
+		return "invalidate"
+	case opResize:
+		return "resize"
+	case opSetTTL:
+		return "set-ttl"
+	case opExpire:
+		return "expire"
+	default:
+		return fmt.Sprintf("op #%d", uint8(o))
+	}
+}
+
 // message is a struct that represents a message that can be sent between nodes.
 type message struct {
 	CacheName string
 	Node      uuid.UUID
 	Key       string
 	Value     interface{}
+	Op        operation
+	Target    uuid.UUID // node that must handle the message, every node when it is nil
+	Request   uint64    // ID of an opGet and its opGetReply
+	// Version of the write, Base and Conditional are set by conditional writes
+	Version     uint64
+	Base        uint64
+	Conditional bool
+	Merge       bool              // the Value is a CRDT delta to merge with the local value
+	Trace       map[string]string // trace context of the write, set by the Tracer
+	Setting     int64             // new setting of opResize and opSetTTL, time-to-live of opExpire
+	codec       Codec             // codec used to encode and decode the Value
+	codecID     uint8             // codec ID received in the envelope
+	payload     []byte            // encoded Value received in the envelope
+	hasValue    bool              // the envelope has a Value, its payload can be empty
+	from        net.IP            // address of the node that sent the message
+	compress    Compression       // algorithm used to compress the payload
+	threshold   int               // minimum payload size to compress
+	batch       []*message        // messages received in a single datagram
+	sent        *sending          // span of the send, ended once the message is written
+}
+
+// envelope is the struct sent over the network,
+// the Value is encoded by the codec of the cache in the Payload.
+type envelope struct {
+	CacheName   string
+	Node        uuid.UUID
+	Key         string
+	Op          operation
+	Target      uuid.UUID
+	Request     uint64
+	Version     uint64
+	Base        uint64
+	Conditional bool
+	Merge       bool
+	Trace       map[string]string
+	Setting     int64
+	Codec       uint8
+	Compression Compression // zero when the payload is not compressed
+	Payload     []byte
+	HasValue    bool       // the Value is not nil, even when it is encoded in an empty Payload
+	Batch       []envelope // messages of the same cache and node sent in a single datagram
+}
+
+// getCodec returns the codec of the message or the default codec,
+// CRDT deltas are always encoded with crdtCodec
+func (m *message) getCodec() Codec {
+	if m.Merge {
+		return crdtCodec
+	}
+	if m.codec == nil {
+		return defaultCodec
+	}
+	return m.codec
 }
 
 // ToUDP serializes the message struct to a byte slice.
 func (m *message) toUDP() ([]byte, error) {
+	env, err := m.toEnvelope()
+	if err != nil {
+		return nil, err
+	}
 	var network bytes.Buffer
 	enc := gob.NewEncoder(&network)
-	err := enc.Encode(m)
+	err = enc.Encode(&env)
 	if err != nil {
 		return nil, err
 	}
 	return network.Bytes(), nil
 }
 
+// toEnvelope encodes the value of the message in an envelope
+func (m *message) toEnvelope() (envelope, error) {
+	codec := m.getCodec()
+	env := envelope{CacheName: m.CacheName, Node: m.Node, Key: m.Key, Codec: codec.ID(),
+		Op: m.Op, Target: m.Target, Request: m.Request,
+		Version: m.Version, Base: m.Base, Conditional: m.Conditional, Merge: m.Merge, Trace: m.Trace,
+		Setting: m.Setting}
+	if m.Value != nil {
+		env.HasValue = true
+		payload, err := codec.Marshal(m.Value)
+		if err != nil {
+			return envelope{}, fmt.Errorf("encoding %q with %s: %w", m.Key, codec.Name(), err)
+		}
+		env.Payload, env.Compression, err = compressPayload(m.compress, m.threshold, payload)
+		if err != nil {
+			return envelope{}, fmt.Errorf("compressing %q with %s: %w", m.Key, m.compress, err)
+		}
+	}
+	return env, nil
+}
+
 // FromUDP deserializes the byte slice to a message struct.
 func (m *message) fromUDP(data []byte) error {
+	if err := m.decodeEnvelope(data); err != nil {
+		return err
+	}
+	return m.decodeValue(m.codec)
+}
+
+// decodeEnvelope deserializes the envelope, the Value is kept encoded
+// until decodeValue is called with the codec of the cache.
+func (m *message) decodeEnvelope(data []byte) error {
+	var env envelope
 	network := bytes.NewBuffer(data)
 	dec := gob.NewDecoder(network)
-	err := dec.Decode(m)
+	err := dec.Decode(&env)
 	if err != nil {
 		return err
 	}
+	return m.fromEnvelope(env)
+}
+
+// fromEnvelope copies the envelope to the message,
+// the messages of a batch share the cache name and node of the datagram
+func (m *message) fromEnvelope(env envelope) error {
+	var err error
+	m.CacheName = env.CacheName
+	m.Node = env.Node
+	m.Key = env.Key
+	m.Op = env.Op
+	m.Target = env.Target
+	m.Request = env.Request
+	m.Version = env.Version
+	m.Base = env.Base
+	m.Conditional = env.Conditional
+	m.Merge = env.Merge
+	m.Trace = env.Trace
+	m.Setting = env.Setting
+	m.codecID = env.Codec
+	// the nodes that do not send HasValue only send values in non-empty payloads
+	m.hasValue = env.HasValue || len(env.Payload) > 0
+	m.payload, err = decompress(env.Compression, env.Payload)
+	if err != nil {
+		return fmt.Errorf("decompressing %q with %s: %w", env.Key, env.Compression, err)
+	}
+	for _, item := range env.Batch {
+		item.CacheName = env.CacheName
+		item.Node = env.Node
+		batched := &message{}
+		if err := batched.fromEnvelope(item); err != nil {
+			return err
+		}
+		m.batch = append(m.batch, batched)
+	}
+	return nil
+}
+
+// messages returns the messages received in the datagram,
+// the message itself when it is not a batch
+func (m *message) messages() []*message {
+	if len(m.batch) > 0 {
+		return m.batch
+	}
+	return []*message{m}
+}
+
+// decodeValue decodes the payload of the envelope with the given codec,
+// when codec is nil the built-in codec matching the envelope is used.
+func (m *message) decodeValue(codec Codec) error {
+	m.codec = codec
+	if !m.hasValue {
+		m.Value = nil
+		return nil
+	}
+	if m.Merge {
+		codec = crdtCodec
+	}
+	if codec == nil {
+		builtin, ok := builtinCodecs[m.codecID]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownCodec, codecName(m.codecID))
+		}
+		codec = builtin
+	}
+	if codec.ID() != m.codecID {
+		return fmt.Errorf("%w: cache %q received %q encoded with %s but uses %s",
+			ErrCodecMismatch, m.CacheName, m.Key, codecName(m.codecID), codec.Name())
+	}
+	value, err := codec.Unmarshal(m.payload)
+	if err != nil {
+		return fmt.Errorf("decoding %q with %s: %w", m.Key, codec.Name(), err)
+	}
+	m.Value = value
 	return nil
 }
 
 // IsCleanMessage returns true if the message is a sendClean message.
 func (m *message) isCleanMessage() bool {
-	return m.Key == cleanMessageKey && m.Value == nil
+	return m.Op == opWrite && m.Key == cleanMessageKey && m.Value == nil
 }
 
 // cleanMessageKey is the key used to send a sendClean message.
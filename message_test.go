@@ -3,6 +3,7 @@ package distributed_cache
 import (
 	"bytes"
 	"encoding/gob"
+	"errors"
 	"testing"
 )
 
@@ -13,17 +14,24 @@ func TestMessage_ToUDP(t *testing.T) {
 		t.Fatalf("ToUDP() error = %v", err)
 	}
 
-	var decodedMsg message
+	var decodedEnv envelope
 	network := bytes.NewBuffer(data)
 	dec := gob.NewDecoder(network)
-	err = dec.Decode(&decodedMsg)
+	err = dec.Decode(&decodedEnv)
 	if err != nil {
 		t.Fatalf("Decode() error = %v", err)
 	}
+	if decodedEnv.Codec != CodecIDGob {
+		t.Errorf("Codec = %v, want %v", decodedEnv.Codec, CodecIDGob)
+	}
+	value, err := GobCodec{}.Unmarshal(decodedEnv.Payload)
+	if err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
 
-	if decodedMsg.CacheName != msg.CacheName || decodedMsg.Key != msg.Key ||
-		decodedMsg.Value != msg.Value {
-		t.Errorf("Decoded message = %v, want %v", decodedMsg, msg)
+	if decodedEnv.CacheName != msg.CacheName || decodedEnv.Key != msg.Key ||
+		value != msg.Value {
+		t.Errorf("Decoded envelope = %v, want %v", decodedEnv, msg)
 	}
 }
 
@@ -46,6 +54,20 @@ func TestMessage_FromUDP(t *testing.T) {
 	}
 }
 
+func TestMessage_FromUDPCodecMismatch(t *testing.T) {
+	msg := &message{CacheName: "testCache", Key: "testKey", Value: "testValue", codec: JSONCodec{}}
+	data, err := msg.toUDP()
+	if err != nil {
+		t.Fatalf("ToUDP() error = %v", err)
+	}
+
+	decodedMsg := message{codec: GobCodec{}}
+	err = decodedMsg.fromUDP(data)
+	if !errors.Is(err, ErrCodecMismatch) {
+		t.Errorf("FromUDP() error = %v, want %v", err, ErrCodecMismatch)
+	}
+}
+
 func TestMessage_IsCleanMessage(t *testing.T) {
 	cleanMsg := &message{Key: cleanMessageKey, Value: nil}
 	if !cleanMsg.isCleanMessage() {
@@ -57,3 +79,27 @@ func TestMessage_IsCleanMessage(t *testing.T) {
 		t.Errorf("IsCleanMessage() = true, want false")
 	}
 }
+
+// rawCodec encodes strings as their bytes, the empty string is an empty payload
+type rawCodec struct{}
+
+func (rawCodec) ID() uint8                                  { return 200 }
+func (rawCodec) Name() string                               { return "raw" }
+func (rawCodec) Marshal(value interface{}) ([]byte, error)  { return []byte(value.(string)), nil }
+func (rawCodec) Unmarshal(data []byte) (interface{}, error) { return string(data), nil }
+
+func TestMessage_FromUDPEmptyPayload(t *testing.T) {
+	msg := &message{CacheName: "testCache", Key: "testKey", Value: "", codec: rawCodec{}}
+	data, err := msg.toUDP()
+	if err != nil {
+		t.Fatalf("ToUDP() error = %v", err)
+	}
+
+	decodedMsg := message{codec: rawCodec{}}
+	if err = decodedMsg.fromUDP(data); err != nil {
+		t.Fatalf("FromUDP() error = %v", err)
+	}
+	if decodedMsg.Value != "" {
+		t.Errorf("Value = %v, want the empty string", decodedMsg.Value)
+	}
+}
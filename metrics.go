@@ -0,0 +1,164 @@
+package distributed_cache
+
+// In this file, you can find the exporters of the statistics of the caches.
+// MetricsHandler writes them in the Prometheus text exposition format and
+// PublishExpvar adds them to the "distributed_cache" expvar, in both cases
+// labelled by the Name of the cache and the UUID of the node.
+
+import (
+	"expvar"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MetricsSource is implemented by every cache type
+type MetricsSource interface {
+	Stats() Stats
+	Node() uuid.UUID
+	getName() string
+}
+
+// Node returns the UUID of the node of the cache
+func (c *Cache) Node() uuid.UUID {
+	return c.node
+}
+
+// metric is a metric of the Prometheus exposition
+type metric struct {
+	name  string
+	help  string
+	kind  string // counter or gauge
+	value func(Stats) float64
+	label string // extra label of the metric, like reason="capacity"
+}
+
+var metrics = []metric{
+	{name: "dcache_hits_total", help: "Keys found in the cache.", kind: "counter",
+		value: func(s Stats) float64 { return float64(s.Hits) }},
+	{name: "dcache_misses_total", help: "Keys not found in the cache.", kind: "counter",
+		value: func(s Stats) float64 { return float64(s.Misses) }},
+	{name: "dcache_fills_total", help: "Calls to the fillers.", kind: "counter",
+		value: func(s Stats) float64 { return float64(s.Fills) }},
+	{name: "dcache_fill_errors_total", help: "Calls to the fillers that returned an error.", kind: "counter",
+		value: func(s Stats) float64 { return float64(s.FillErrors) }},
+	{name: "dcache_evictions_total", help: "Entries removed by the cache, by reason.", kind: "counter",
+		value: func(s Stats) float64 { return float64(s.Evictions.Capacity) }, label: `reason="capacity"`},
+	{name: "dcache_evictions_total", kind: "counter",
+		value: func(s Stats) float64 { return float64(s.Evictions.Expired) }, label: `reason="expired"`},
+	{name: "dcache_entries", help: "Entries in the cache.", kind: "gauge",
+		value: func(s Stats) float64 { return float64(s.Entries) }},
+	{name: "dcache_bytes", help: "Approximate size of the keys and values in the cache.", kind: "gauge",
+		value: func(s Stats) float64 { return float64(s.Bytes) }},
+	{name: "dcache_messages_sent_total", help: "Messages sent to the other nodes.", kind: "counter",
+		value: func(s Stats) float64 { return float64(s.Network.Sent) }},
+	{name: "dcache_messages_dropped_total", help: "Messages that could not be sent.", kind: "counter",
+		value: func(s Stats) float64 { return float64(s.Network.Dropped) }},
+	{name: "dcache_messages_received_total", help: "Messages received from the other nodes.", kind: "counter",
+		value: func(s Stats) float64 { return float64(s.Network.Received) }},
+	{name: "dcache_decode_errors_total", help: "Messages that could not be decoded.", kind: "counter",
+		value: func(s Stats) float64 { return float64(s.Network.DecodeErrors) }},
+	{name: "dcache_messages_ignored_total", help: "Messages received for other caches.", kind: "counter",
+		value: func(s Stats) float64 { return float64(s.Network.IgnoredByName) }},
+}
+
+// MetricsHandler returns a handler that writes the statistics of the caches
+// in the Prometheus text exposition format
+func MetricsHandler(caches ...MetricsSource) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WriteMetrics(w, caches...)
+	})
+}
+
+// WriteMetrics writes the statistics of the caches in the Prometheus text exposition format
+func WriteMetrics(w io.Writer, caches ...MetricsSource) {
+	stats := make([]Stats, len(caches))
+	labels := make([]string, len(caches))
+	for i, c := range caches {
+		stats[i] = c.Stats()
+		labels[i] = fmt.Sprintf(`cache="%s",node="%s"`, escapeLabel(c.getName()), c.Node())
+	}
+	for _, m := range metrics {
+		if m.help != "" {
+			fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+		}
+		for i := range caches {
+			label := labels[i]
+			if m.label != "" {
+				label += "," + m.label
+			}
+			fmt.Fprintf(w, "%s{%s} %s\n", m.name, label, formatFloat(m.value(stats[i])))
+		}
+	}
+	const name = "dcache_fill_duration_seconds"
+	fmt.Fprintf(w, "# HELP %s Latency of the calls to the fillers.\n# TYPE %s histogram\n", name, name)
+	for i := range caches {
+		histogram := stats[i].FillLatency
+		var cumulative uint64
+		for j, bound := range histogram.Bounds {
+			cumulative += histogram.Counts[j]
+			fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels[i], formatFloat(bound.Seconds()), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels[i], histogram.Count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", name, labels[i], formatFloat(histogram.Sum.Seconds()))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels[i], histogram.Count)
+	}
+}
+
+// escapeLabel escapes a label value of the Prometheus exposition
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// expvarCaches are the caches published with PublishExpvar by cache name and node
+var (
+	expvarCaches = make(map[string]MetricsSource)
+	expvarMutex  sync.Mutex
+	expvarOnce   sync.Once
+)
+
+// PublishExpvar adds the statistics of a cache to the "distributed_cache" expvar,
+// a map of the caches by "name/node"
+func PublishExpvar(c MetricsSource) {
+	expvarOnce.Do(func() {
+		expvar.Publish("distributed_cache", expvar.Func(expvarStats))
+	})
+	expvarMutex.Lock()
+	defer expvarMutex.Unlock()
+	expvarCaches[expvarKey(c)] = c
+}
+
+// UnpublishExpvar removes a cache from the "distributed_cache" expvar
+func UnpublishExpvar(c MetricsSource) {
+	expvarMutex.Lock()
+	defer expvarMutex.Unlock()
+	delete(expvarCaches, expvarKey(c))
+}
+
+func expvarKey(c MetricsSource) string {
+	return c.getName() + "/" + c.Node().String()
+}
+
+// expvarStats returns the statistics of the published caches
+func expvarStats() interface{} {
+	expvarMutex.Lock()
+	caches := make(map[string]MetricsSource, len(expvarCaches))
+	for key, c := range expvarCaches {
+		caches[key] = c
+	}
+	expvarMutex.Unlock()
+	out := make(map[string]Stats, len(caches))
+	for key, c := range caches {
+		out[key] = c.Stats()
+	}
+	return out
+}
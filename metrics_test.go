@@ -0,0 +1,58 @@
+package distributed_cache
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	c := NewCache("metrics\"Cache", "255.255.255.255", ":12379")
+	defer c.StopListener()
+	c.Set("key", "value")
+	c.Get("key")
+	c.Get("missing")
+
+	recorder := httptest.NewRecorder()
+	MetricsHandler(c).ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+	labels := `cache="metrics\"Cache",node="` + c.Node().String() + `"`
+	expected := []string{
+		"# TYPE dcache_hits_total counter",
+		"dcache_hits_total{" + labels + "} 1\n",
+		"dcache_misses_total{" + labels + "} 1\n",
+		"dcache_evictions_total{" + labels + `,reason="expired"} 0` + "\n",
+		"dcache_entries{" + labels + "} 1\n",
+		"# TYPE dcache_fill_duration_seconds histogram",
+		"dcache_fill_duration_seconds_bucket{" + labels + `,le="+Inf"} 0` + "\n",
+	}
+	for _, line := range expected {
+		if !strings.Contains(body, line) {
+			t.Errorf("Expected %q in the metrics:\n%s", line, body)
+		}
+	}
+	if strings.Count(body, "# TYPE dcache_evictions_total") != 1 {
+		t.Errorf("Expected a single TYPE line for dcache_evictions_total:\n%s", body)
+	}
+	if contentType := recorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain") {
+		t.Errorf("Expected a text content type, got %s", contentType)
+	}
+}
+
+func TestPublishExpvar(t *testing.T) {
+	c := NewCache("expvarCache", "255.255.255.255", ":12380")
+	defer c.StopListener()
+	c.Set("key", "value")
+	PublishExpvar(c)
+	defer UnpublishExpvar(c)
+
+	var published map[string]Stats
+	if err := json.Unmarshal([]byte(expvar.Get("distributed_cache").String()), &published); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if stats, ok := published["expvarCache/"+c.Node().String()]; !ok || stats.Entries != 1 {
+		t.Errorf("Expected the stats of the cache, got %v", published)
+	}
+}
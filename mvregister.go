@@ -0,0 +1,134 @@
+package distributed_cache
+
+// In this file, you can find the MVRegister CRDT and the methods WriteRegister
+// and ReadRegister. A multi-value register keeps every value written
+// concurrently in different nodes, using version vectors to know which writes
+// have seen the others, until a new write replaces all of them.
+// The values are encoded with the codec of the cache.
+
+import (
+	"bytes"
+	"context"
+	"slices"
+)
+
+// MVRegister is a multi-value register
+type MVRegister struct {
+	Values []MVValue
+}
+
+// MVValue is a value of a MVRegister with the version vector of its write
+type MVValue struct {
+	Value []byte            // encoded with the codec of the cache
+	Clock map[string]uint64 // writes seen by each node
+}
+
+// dominates returns true when the write has seen the other write,
+// and they are not the same write
+func (v MVValue) dominates(other MVValue) bool {
+	greater := false
+	for node, count := range other.Clock {
+		if v.Clock[node] < count {
+			return false
+		}
+	}
+	for node, count := range v.Clock {
+		if count > other.Clock[node] {
+			greater = true
+		}
+	}
+	return greater
+}
+
+// equals returns true when both values have the same version vector
+func (v MVValue) equals(other MVValue) bool {
+	if len(v.Clock) != len(other.Clock) {
+		return false
+	}
+	for node, count := range v.Clock {
+		if other.Clock[node] != count {
+			return false
+		}
+	}
+	return true
+}
+
+// Merge returns the values of both registers that have not been replaced
+// by a newer write
+func (r *MVRegister) Merge(other CRDT) CRDT {
+	var candidates []MVValue
+	otherRegister, _ := other.(*MVRegister)
+	for _, register := range []*MVRegister{r, otherRegister} {
+		if register != nil {
+			candidates = append(candidates, register.Values...)
+		}
+	}
+	merged := &MVRegister{}
+	for i, candidate := range candidates {
+		keep := true
+		for j, value := range candidates {
+			if value.dominates(candidate) || (j < i && value.equals(candidate)) {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			merged.Values = append(merged.Values, candidate)
+		}
+	}
+	// the same order in every node
+	slices.SortFunc(merged.Values, func(a, b MVValue) int {
+		return bytes.Compare(a.Value, b.Value)
+	})
+	return merged
+}
+
+// writeDelta returns the delta that replaces all the current values
+func (r *MVRegister) writeDelta(node string, value []byte) *MVRegister {
+	clock := make(map[string]uint64)
+	if r != nil {
+		for _, current := range r.Values {
+			for name, count := range current.Clock {
+				if count > clock[name] {
+					clock[name] = count
+				}
+			}
+		}
+	}
+	clock[node]++
+	return &MVRegister{Values: []MVValue{{Value: value, Clock: clock}}}
+}
+
+// WriteRegister writes the register of a key, replacing all the values seen
+// by this node, and sends the change to the other nodes.
+// A value of another type is replaced
+func (c *Cache) WriteRegister(key string, value interface{}) error {
+	data, err := c.getCodec().Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = c.mutate(context.Background(), key, func(current interface{}) CRDT {
+		register, _ := current.(*MVRegister)
+		return register.writeDelta(c.node.String(), data)
+	})
+	return err
+}
+
+// ReadRegister returns the values of the register of a key decoded with the
+// codec of the cache, more than one when they were written concurrently
+func (c *Cache) ReadRegister(key string) ([]interface{}, error) {
+	register, _ := c.readCRDT(key).(*MVRegister)
+	out := make([]interface{}, 0)
+	if register == nil {
+		return out, nil
+	}
+	codec := c.getCodec()
+	for _, value := range register.Values {
+		decoded, err := codec.Unmarshal(value.Value)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, decoded)
+	}
+	return out, nil
+}
@@ -0,0 +1,46 @@
+package distributed_cache
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCache_WriteRegister(t *testing.T) {
+	cache.Delete("register")
+	if err := cache.WriteRegister("register", "value1"); err != nil {
+		t.Fatalf("WriteRegister() error = %v", err)
+	}
+	if err := cache.WriteRegister("register", "value2"); err != nil {
+		t.Fatalf("WriteRegister() error = %v", err)
+	}
+
+	values, err := cache.ReadRegister("register")
+	if err != nil {
+		t.Fatalf("ReadRegister() error = %v", err)
+	}
+	if !reflect.DeepEqual(values, []interface{}{"value2"}) {
+		t.Errorf("Expected [value2], got %v", values)
+	}
+}
+
+func TestMVRegister_KeepsConcurrentWrites(t *testing.T) {
+	var register *MVRegister
+	base := register.writeDelta("a", []byte("base"))
+	// two nodes write after seeing the same value
+	a := base.Merge(base.writeDelta("a", []byte("from-a"))).(*MVRegister)
+	b := base.Merge(base.writeDelta("b", []byte("from-b"))).(*MVRegister)
+
+	merged := a.Merge(b).(*MVRegister)
+	if !reflect.DeepEqual(merged, b.Merge(a)) {
+		t.Errorf("Expected the same result in both orders")
+	}
+	if len(merged.Values) != 2 {
+		t.Fatalf("Expected 2 concurrent values, got %v", merged.Values)
+	}
+
+	// a write that has seen both values replaces them
+	resolved := merged.Merge(merged.writeDelta("a", []byte("resolved"))).(*MVRegister)
+	if len(resolved.Values) != 1 || string(resolved.Values[0].Value) != "resolved" {
+		t.Errorf("Expected only the resolved value, got %v", resolved.Values)
+	}
+}
@@ -0,0 +1,72 @@
+package distributed_cache
+
+// In this file, you can find what tools like cmd/dcache use to join a cluster
+// without being part of it. An Observer node receives the messages of the
+// other nodes but sends no heartbeats, so it is never a member or an owner of
+// the keys. The MessageHook is called with every message received, and
+// GetRemote asks the members for a key that is not stored in the node.
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Event is a message received from another node, passed to the MessageHook
+type Event struct {
+	Op      string // write, heartbeat, get, get-reply, invalidate, resize, set-ttl or expire
+	Key     string
+	Value   interface{} // nil for the deletes
+	Node    uuid.UUID   // node that sent the message
+	Version uint64
+	Clean   bool // the write removes every key
+}
+
+// WithObserver receives the messages of the other nodes without sending
+// heartbeats, so the node is not a member of the cluster
+func WithObserver() Option {
+	return func(o *options) error {
+		o.set(func(c *Cache) { c.Observer = true })
+		return nil
+	}
+}
+
+// WithMessageHook calls hook with every message received from the other nodes
+func WithMessageHook(hook func(Event)) Option {
+	return func(o *options) error {
+		o.set(func(c *Cache) { c.MessageHook = hook })
+		return nil
+	}
+}
+
+// GetRemote asks the other nodes for a key at once, without filling it,
+// it returns false when no node answers with a value before its ForwardTimeout
+func (c *Cache) GetRemote(key string) (interface{}, bool) {
+	if answer := c.askAny(key); answer != nil {
+		return answer.Value, true
+	}
+	return nil, false
+}
+
+// notify calls the MessageHook with a message received from another node
+func (c *Cache) notify(message *message) {
+	if c.MessageHook == nil {
+		return
+	}
+	c.MessageHook(Event{Op: message.Op.String(), Key: message.Key, Value: message.Value, Node: message.Node,
+		Version: message.Version, Clean: message.isCleanMessage()})
+}
+
+// WaitMembers waits until the node knows another member, it returns false
+// when no heartbeat is received before the timeout
+func (c *Cache) WaitMembers(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if len(c.Members()) > 1 {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,56 @@
+package distributed_cache
+
+import (
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func TestCache_Observer(t *testing.T) {
+	var events []Event
+	c, err := New("observedCache", WithTransport("", ":12398"), WithObserver(),
+		WithMessageHook(func(event Event) { events = append(events, event) }))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer c.StopListener()
+	time.Sleep(50 * time.Millisecond)
+	if sent := c.BatchStats().Messages; sent != 0 {
+		t.Errorf("Expected no heartbeats from an observer, got %d messages", sent)
+	}
+
+	remote := uuid.New()
+	c.handleMessage(&message{CacheName: "observedCache", Node: remote, Op: opHeartbeat})
+	c.handleMessage(&message{CacheName: "observedCache", Node: remote, Key: "key", Value: "value", Version: 1})
+	if len(events) != 2 || events[0].Op != "heartbeat" || events[1].Key != "key" || events[1].Value != "value" {
+		t.Errorf("Expected the heartbeat and the write, got %+v", events)
+	}
+	if !c.WaitMembers(time.Millisecond) {
+		t.Errorf("Expected the remote member")
+	}
+}
+
+func TestCache_GetRemote(t *testing.T) {
+	c := NewCache("remoteCache", "255.255.255.255", ":12399")
+	defer c.StopListener()
+	if _, ok := c.GetRemote("key"); ok {
+		t.Errorf("Expected no value without members")
+	}
+	remote := uuid.New()
+	c.handleMessage(&message{CacheName: "remoteCache", Node: remote, Op: opHeartbeat})
+	go func() {
+		for {
+			c.requestsMutex.Lock()
+			for id := range c.requests {
+				c.requestsMutex.Unlock()
+				c.deliverReply(&message{Node: remote, Key: "key", Value: "value", Op: opGetReply, Request: id})
+				return
+			}
+			c.requestsMutex.Unlock()
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	if value, ok := c.GetRemote("key"); !ok || value != "value" {
+		t.Errorf("Expected value from the member, got %v", value)
+	}
+}
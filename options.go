@@ -0,0 +1,377 @@
+package distributed_cache
+
+// In this file, you can find New, the constructor of the caches with
+// functional options. The options are validated and applied before the
+// listener starts, so no field is changed while the cache is running, and the
+// errors, including the ones of the network, are returned instead of exiting.
+// With WithMaxEntries the cache evicts the least recently used entries and
+// with WithTTL the entries that are not accessed expire.
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"log/slog"
+	"time"
+)
+
+// DefaultBroadcast is the broadcast address used when WithTransport does not set one
+const DefaultBroadcast = "255.255.255.255"
+
+// ConfigError is returned by New when an option is not valid
+type ConfigError struct {
+	Field  string // name of the option or the field of Config
+	Reason string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Reason)
+}
+
+// Option configures a cache created with New
+type Option func(*options) error
+
+// options are the settings of New
+type options struct {
+	broadcast     string
+	address       string
+	maxEntries    int
+	ttl           time.Duration
+	flushInterval time.Duration
+	maxBatchBytes int
+	key           []byte
+	logDir        string
+	logOptions    LogOptions
+	snapshotPath  string
+	snapshotEvery time.Duration
+	apply         []func(c *Cache) // settings of the exported fields
+}
+
+// set adds a setting of the exported fields of the cache
+func (o *options) set(apply func(c *Cache)) {
+	o.apply = append(o.apply, apply)
+}
+
+// WithTransport sets the broadcast address of the network and the address
+// the nodes listen on, like ":12345", DefaultBroadcast when broadcast is empty
+func WithTransport(broadcast, address string) Option {
+	return func(o *options) error {
+		if address == "" {
+			return &ConfigError{Field: "address", Reason: "must not be empty"}
+		}
+		if broadcast != "" {
+			o.broadcast = broadcast
+		}
+		o.address = address
+		return nil
+	}
+}
+
+// WithBatching sets the time a message waits to be sent with other messages
+// and the maximum size of a datagram
+func WithBatching(flushInterval time.Duration, maxBytes int) Option {
+	return func(o *options) error {
+		if flushInterval < 0 {
+			return &ConfigError{Field: "flush interval", Reason: "must not be negative"}
+		}
+		if maxBytes < 0 || maxBytes > datagramSize {
+			return &ConfigError{Field: "max batch bytes", Reason: fmt.Sprintf("must be between 0 and %d", datagramSize)}
+		}
+		o.flushInterval, o.maxBatchBytes = flushInterval, maxBytes
+		return nil
+	}
+}
+
+// WithMaxEntries evicts the least recently used entries when the cache has more than n
+func WithMaxEntries(n int) Option {
+	return func(o *options) error {
+		if n < 0 {
+			return &ConfigError{Field: "max entries", Reason: "must not be negative"}
+		}
+		o.maxEntries = n
+		return nil
+	}
+}
+
+// WithTTL removes the entries that are not accessed for ttl
+func WithTTL(ttl time.Duration) Option {
+	return func(o *options) error {
+		if ttl < 0 {
+			return &ConfigError{Field: "ttl", Reason: "must not be negative"}
+		}
+		o.ttl = ttl
+		return nil
+	}
+}
+
+// WithFiller sets the function that fills the keys that are not found
+func WithFiller(filler func(string) (interface{}, error)) Option {
+	return func(o *options) error {
+		o.set(func(c *Cache) { c.Filler = filler })
+		return nil
+	}
+}
+
+// WithBatchFiller sets the function that fills many keys at once in GetMany
+func WithBatchFiller(filler func([]string) (map[string]interface{}, error)) Option {
+	return func(o *options) error {
+		o.set(func(c *Cache) { c.BatchFiller = filler })
+		return nil
+	}
+}
+
+// WithRemoveHook sets the function called with the keys removed from the cache
+func WithRemoveHook(hook func(string, interface{})) Option {
+	return func(o *options) error {
+		o.set(func(c *Cache) { c.RemoveHook = hook })
+		return nil
+	}
+}
+
+// WithWriter persists the local writes with writer, see WriteMode
+func WithWriter(writer Writer, mode WriteMode) Option {
+	return func(o *options) error {
+		if writer == nil {
+			return &ConfigError{Field: "writer", Reason: "must not be nil"}
+		}
+		if mode != WriteThrough && mode != WriteBehind {
+			return &ConfigError{Field: "write mode", Reason: fmt.Sprintf("unknown mode %d", mode)}
+		}
+		o.set(func(c *Cache) { c.Writer, c.WriteMode = writer, mode })
+		return nil
+	}
+}
+
+// WithBackend puts the cache in front of a shared store
+func WithBackend(backend Backend) Option {
+	return func(o *options) error {
+		if backend == nil {
+			return &ConfigError{Field: "backend", Reason: "must not be nil"}
+		}
+		o.set(func(c *Cache) { c.Backend = backend })
+		return nil
+	}
+}
+
+// WithCodec sets the codec of the values sent to the other nodes
+func WithCodec(codec Codec) Option {
+	return func(o *options) error {
+		if codec == nil {
+			return &ConfigError{Field: "codec", Reason: "must not be nil"}
+		}
+		o.set(func(c *Cache) { c.Codec = codec })
+		return nil
+	}
+}
+
+// WithCompression compresses the values of at least threshold bytes,
+// DefaultCompressionThreshold when threshold is 0
+func WithCompression(compression Compression, threshold int) Option {
+	return func(o *options) error {
+		if compression > CompressionFlate {
+			return &ConfigError{Field: "compression", Reason: ErrUnknownCompression.Error()}
+		}
+		if threshold < 0 {
+			return &ConfigError{Field: "compression threshold", Reason: "must not be negative"}
+		}
+		o.set(func(c *Cache) { c.Compression, c.CompressionThreshold = compression, threshold })
+		return nil
+	}
+}
+
+// WithReplication sets what a write sends to the other nodes
+func WithReplication(mode ReplicationMode) Option {
+	return func(o *options) error {
+		if mode != ReplicationFull && mode != ReplicationInvalidate {
+			return &ConfigError{Field: "replication", Reason: fmt.Sprintf("unknown mode %d", mode)}
+		}
+		o.set(func(c *Cache) { c.Replication = mode })
+		return nil
+	}
+}
+
+// WithPartitioning stores each key only in replicationFactor owners,
+// DefaultReplicationFactor when it is 0
+func WithPartitioning(replicationFactor int) Option {
+	return func(o *options) error {
+		if replicationFactor < 0 {
+			return &ConfigError{Field: "replication factor", Reason: "must not be negative"}
+		}
+		o.set(func(c *Cache) { c.Partitioned, c.ReplicationFactor = true, replicationFactor })
+		return nil
+	}
+}
+
+// WithForwardTimeout sets the time Get waits for the answer of an owner
+func WithForwardTimeout(timeout time.Duration) Option {
+	return func(o *options) error {
+		if timeout < 0 {
+			return &ConfigError{Field: "forward timeout", Reason: "must not be negative"}
+		}
+		o.set(func(c *Cache) { c.ForwardTimeout = timeout })
+		return nil
+	}
+}
+
+// WithConsistency sets the guarantee of the conditional writes
+func WithConsistency(consistency Consistency) Option {
+	return func(o *options) error {
+		if consistency != ConsistencyLocal && consistency != ConsistencyStrong {
+			return &ConfigError{Field: "consistency", Reason: fmt.Sprintf("unknown consistency %d", consistency)}
+		}
+		o.set(func(c *Cache) { c.Consistency = consistency })
+		return nil
+	}
+}
+
+// WithLogger logs the errors of the cache with logger
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) error {
+		o.set(func(c *Cache) { c.Logger = logger })
+		return nil
+	}
+}
+
+// WithTracer creates spans with tracer
+func WithTracer(tracer Tracer) Option {
+	return func(o *options) error {
+		o.set(func(c *Cache) { c.Tracer = tracer })
+		return nil
+	}
+}
+
+// WithSecurity signs the datagrams with a key shared by the nodes and drops the
+// datagrams that are not signed with it or are replayed, the key must have at
+// least MinKeySize bytes and the clocks of the nodes must be within MaxClockSkew
+func WithSecurity(key []byte) Option {
+	return func(o *options) error {
+		if len(key) < MinKeySize {
+			return &ConfigError{Field: "security key", Reason: fmt.Sprintf("must have at least %d bytes", MinKeySize)}
+		}
+		o.key = append([]byte(nil), key...)
+		return nil
+	}
+}
+
+// WithSnapshots restores the snapshot of path and writes it every interval,
+// see EnableSnapshots, before the cache receives any change
+func WithSnapshots(path string, interval time.Duration) Option {
+	return func(o *options) error {
+		if path == "" {
+			return &ConfigError{Field: "snapshot path", Reason: "must not be empty"}
+		}
+		if interval < 0 {
+			return &ConfigError{Field: "snapshot interval", Reason: "must not be negative"}
+		}
+		o.snapshotPath, o.snapshotEvery = path, interval
+		return nil
+	}
+}
+
+// WithLog enables the write-ahead log of dir, see EnableLog, before the cache
+// receives any change
+func WithLog(dir string, logOptions LogOptions) Option {
+	return func(o *options) error {
+		if dir == "" {
+			return &ConfigError{Field: "log dir", Reason: "must not be empty"}
+		}
+		if logOptions.Sync > SyncNever {
+			return &ConfigError{Field: "log sync", Reason: fmt.Sprintf("unknown policy %d", logOptions.Sync)}
+		}
+		o.logDir, o.logOptions = dir, logOptions
+		return nil
+	}
+}
+
+// New creates a cache with the given name and options and starts it.
+// WithTransport is required, the other options are optional
+func New(name string, opts ...Option) (*Cache, error) {
+	if name == "" {
+		return nil, &ConfigError{Field: "name", Reason: "must not be empty"}
+	}
+	o := options{broadcast: DefaultBroadcast}
+	for _, opt := range opts {
+		if err := opt(&o); err != nil {
+			return nil, err
+		}
+	}
+	if o.address == "" {
+		return nil, &ConfigError{Field: "address", Reason: "is required, use WithTransport"}
+	}
+	c := newEntries(o.maxEntries, o.ttl)
+	ctx, cancel := context.WithCancel(context.Background())
+	c.Name = name
+	c.Address = o.address
+	c.Broadcast = o.broadcast
+	c.storage = make(map[string]interface{})
+	c.context = ctx
+	c.StopListener = cancel
+	c.node = uuid.New()
+	c.FlushInterval = o.flushInterval
+	c.MaxBatchBytes = o.maxBatchBytes
+	c.key = o.key
+	for _, apply := range o.apply {
+		apply(c)
+	}
+	// abort releases what was started, the snapshots only start when the
+	// cache does, so a cache that fails to start does not write one
+	abort := func(err error) (*Cache, error) {
+		cancel()
+		if c.wal != nil {
+			<-c.wal.done
+		}
+		return nil, err
+	}
+	if o.snapshotPath != "" {
+		if err := c.restoreIfExists(o.snapshotPath); err != nil {
+			return abort(err)
+		}
+	}
+	if o.logDir != "" {
+		if err := c.EnableLog(o.logDir, o.logOptions); err != nil {
+			return abort(err)
+		}
+	}
+
+	listener, err := listen(o.address)
+	if err != nil {
+		return abort(err)
+	}
+	conn, err := dialBroadcast(o.broadcast, o.address)
+	if err != nil {
+		listener.Close()
+		return abort(err)
+	}
+	if o.snapshotPath != "" {
+		c.startSnapshots(o.snapshotPath, o.snapshotEvery)
+	}
+	c.senderOnce.Do(func() {
+		c.startSender(conn)
+	})
+	go serve(c, ctx, listener)
+	go c.startHeartbeat(ctx)
+	return c, nil
+}
+
+// newEntries creates the cache type for the eviction settings
+// and returns its Cache
+func newEntries(maxEntries int, ttl time.Duration) *Cache {
+	switch {
+	case ttl > 0:
+		c := &LRUCacheWithTTL{
+			LRUCache: LRUCache{MaxEntries: maxEntries, queue: make([]string, 0)},
+			TTL:      ttl,
+			ttlMap:   make(map[string]time.Time),
+		}
+		c.entries = c
+		return &c.Cache
+	case maxEntries > 0:
+		c := &LRUCache{MaxEntries: maxEntries, queue: make([]string, 0)}
+		c.entries = c
+		return &c.Cache
+	default:
+		c := &Cache{}
+		c.entries = c
+		return c
+	}
+}
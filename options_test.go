@@ -0,0 +1,81 @@
+package distributed_cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNew_Validation(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []Option
+		field string
+	}{
+		{"empty name", nil, "name"},
+		{"cache", nil, "address"},
+		{"cache", []Option{WithTransport("", "")}, "address"},
+		{"cache", []Option{WithTransport("", ":12385"), WithMaxEntries(-1)}, "max entries"},
+		{"cache", []Option{WithTransport("", ":12385"), WithTTL(-time.Second)}, "ttl"},
+		{"cache", []Option{WithTransport("", ":12385"), WithSecurity([]byte("short"))}, "security key"},
+		{"cache", []Option{WithTransport("", ":12385"), WithCodec(nil)}, "codec"},
+		{"cache", []Option{WithTransport("", ":12385"), WithBatching(0, 4096)}, "max batch bytes"},
+		{"cache", []Option{WithTransport("", ":12385"), WithLog("", LogOptions{})}, "log dir"},
+		{"cache", []Option{WithTransport("", ":12385"), WithSnapshots("", 0)}, "snapshot path"},
+		{"cache", []Option{WithTransport("", ":12385"), WithForwardTimeout(-time.Second)}, "forward timeout"},
+	}
+	for _, test := range tests {
+		name := test.name
+		if name == "empty name" {
+			name = ""
+		}
+		_, err := New(name, test.opts...)
+		var configErr *ConfigError
+		if !errors.As(err, &configErr) || configErr.Field != test.field {
+			t.Errorf("Expected an error of %s, got %v", test.field, err)
+		}
+	}
+}
+
+func TestNew_LRUWithTTL(t *testing.T) {
+	var removed []string
+	c, err := New("newCache", WithTransport("", ":12386"), WithMaxEntries(2), WithTTL(time.Hour),
+		WithRemoveHook(func(key string, value interface{}) { removed = append(removed, key) }))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer c.StopListener()
+	if _, ok := c.entries.(*LRUCacheWithTTL); !ok {
+		t.Fatalf("Expected an LRUCacheWithTTL, got %T", c.entries)
+	}
+	c.Set("key1", "value1")
+	c.Set("key2", "value2")
+	c.Set("key3", "value3")
+	if val := c.Get("key1"); val != nil {
+		t.Errorf("Expected key1 to be evicted, got %v", val)
+	}
+	if val := c.Get("key3"); val != "value3" {
+		t.Errorf("Expected value3, got %v", val)
+	}
+
+	// the address is already in use
+	if _, err := New("newCache", WithTransport("", ":12386")); err == nil {
+		t.Errorf("Expected an error listening on the same address")
+	}
+}
+
+func TestNew_Unbounded(t *testing.T) {
+	c, err := New("newCache", WithTransport("", ":12387"), WithFiller(func(key string) (interface{}, error) {
+		return "filled", nil
+	}))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer c.StopListener()
+	if c.entries != c {
+		t.Errorf("Expected a Cache, got %T", c.entries)
+	}
+	if val := c.Get("key"); val != "filled" {
+		t.Errorf("Expected filled, got %v", val)
+	}
+}
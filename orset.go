@@ -0,0 +1,125 @@
+package distributed_cache
+
+// In this file, you can find the ORSet CRDT and the methods AddToSet,
+// RemoveFromSet and SetMembers. Every add creates a unique tag and a remove
+// only removes the tags it has observed, so an element added in a node while
+// it is removed in another one stays in the set (add wins).
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"slices"
+)
+
+// ORSet is an observed-remove set of strings.
+// Adds keeps the tags of each element and Removes the tags removed
+type ORSet struct {
+	Adds    map[string]map[string]bool
+	Removes map[string]bool
+}
+
+// Members returns the elements of the set in order
+func (s *ORSet) Members() []string {
+	members := make([]string, 0)
+	if s == nil {
+		return members
+	}
+	for element, tags := range s.Adds {
+		for tag := range tags {
+			if !s.Removes[tag] {
+				members = append(members, element)
+				break
+			}
+		}
+	}
+	slices.Sort(members)
+	return members
+}
+
+// Contains returns true when the element is in the set
+func (s *ORSet) Contains(element string) bool {
+	if s == nil {
+		return false
+	}
+	for tag := range s.Adds[element] {
+		if !s.Removes[tag] {
+			return true
+		}
+	}
+	return false
+}
+
+// Merge returns the union of the tags added and removed in both sets
+func (s *ORSet) Merge(other CRDT) CRDT {
+	merged := &ORSet{Adds: make(map[string]map[string]bool), Removes: make(map[string]bool)}
+	otherSet, _ := other.(*ORSet)
+	for _, set := range []*ORSet{s, otherSet} {
+		if set == nil {
+			continue
+		}
+		for element, tags := range set.Adds {
+			if merged.Adds[element] == nil {
+				merged.Adds[element] = make(map[string]bool)
+			}
+			for tag := range tags {
+				merged.Adds[element][tag] = true
+			}
+		}
+		for tag := range set.Removes {
+			merged.Removes[tag] = true
+		}
+	}
+	return merged
+}
+
+// addDelta returns the delta that adds the elements with new tags
+func (s *ORSet) addDelta(elements []string) *ORSet {
+	delta := &ORSet{Adds: make(map[string]map[string]bool), Removes: make(map[string]bool)}
+	for _, element := range elements {
+		delta.Adds[element] = map[string]bool{uuid.NewString(): true}
+	}
+	return delta
+}
+
+// removeDelta returns the delta that removes the observed tags of the elements
+func (s *ORSet) removeDelta(elements []string) *ORSet {
+	delta := &ORSet{Adds: make(map[string]map[string]bool), Removes: make(map[string]bool)}
+	if s == nil {
+		return delta
+	}
+	for _, element := range elements {
+		for tag := range s.Adds[element] {
+			delta.Removes[tag] = true
+		}
+	}
+	return delta
+}
+
+// AddToSet adds elements to the set of a key and sends the change to the other nodes,
+// it returns the members of the set. A value of another type is replaced
+func (c *Cache) AddToSet(key string, elements ...string) []string {
+	value, _ := c.mutate(context.Background(), key, func(current interface{}) CRDT {
+		set, _ := current.(*ORSet)
+		return set.addDelta(elements)
+	})
+	set, _ := value.(*ORSet)
+	return set.Members()
+}
+
+// RemoveFromSet removes elements from the set of a key and sends the change
+// to the other nodes, it returns the members of the set
+func (c *Cache) RemoveFromSet(key string, elements ...string) []string {
+	value, _ := c.mutate(context.Background(), key, func(current interface{}) CRDT {
+		set, _ := current.(*ORSet)
+		return set.removeDelta(elements)
+	})
+	set, _ := value.(*ORSet)
+	return set.Members()
+}
+
+// SetMembers returns the members of the set of a key,
+// empty when it is not a set
+func (c *Cache) SetMembers(key string) []string {
+	set, _ := c.readCRDT(key).(*ORSet)
+	return set.Members()
+}
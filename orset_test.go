@@ -0,0 +1,49 @@
+package distributed_cache
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCache_AddToSet(t *testing.T) {
+	cache.Delete("flags")
+	cache.AddToSet("flags", "beta", "dark-mode")
+	members := cache.RemoveFromSet("flags", "beta")
+
+	if !reflect.DeepEqual(members, []string{"dark-mode"}) {
+		t.Errorf("Expected [dark-mode], got %v", members)
+	}
+	if !reflect.DeepEqual(cache.SetMembers("flags"), members) {
+		t.Errorf("Expected %v, got %v", members, cache.SetMembers("flags"))
+	}
+}
+
+func TestORSet_ConcurrentAddWins(t *testing.T) {
+	var set *ORSet
+	base := set.addDelta([]string{"beta"})
+	// a node removes the element while another one adds it again
+	removed := base.Merge(base.removeDelta([]string{"beta"})).(*ORSet)
+	added := base.Merge(base.addDelta([]string{"beta"})).(*ORSet)
+
+	if merged := removed.Merge(added).(*ORSet); !merged.Contains("beta") {
+		t.Errorf("Expected beta in the merged set")
+	}
+	if merged := added.Merge(removed).(*ORSet); !merged.Contains("beta") {
+		t.Errorf("Expected beta in the merged set")
+	}
+	if removed.Contains("beta") {
+		t.Errorf("Expected beta to be removed")
+	}
+}
+
+func TestCache_AddToSetMergesRemoteDelta(t *testing.T) {
+	cache.Delete("remote-flags")
+	cache.AddToSet("remote-flags", "local")
+	var set *ORSet
+	cache.applyRemote(&message{CacheName: cache.Name, Key: "remote-flags",
+		Value: set.addDelta([]string{"remote"}), Merge: true})
+
+	if members := cache.SetMembers("remote-flags"); !reflect.DeepEqual(members, []string{"local", "remote"}) {
+		t.Errorf("Expected [local remote], got %v", members)
+	}
+}
@@ -0,0 +1,183 @@
+package distributed_cache
+
+// In this file, you can find the partitioned mode. Instead of storing every key
+// in every node, each key is stored only by ReplicationFactor owners chosen with
+// the consistent hash ring of the members, so the capacity grows with the
+// cluster. The writes are still broadcast, but only the owners store them,
+// and Get in a node that does not own the key asks the owners for it.
+// The CRDT values and the conditional writes follow the ownership of the keys
+// like Set: a node that does not own the key changes the value of the owners.
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultReplicationFactor is the number of owners of each key
+// when the cache does not set ReplicationFactor
+const DefaultReplicationFactor = 2
+
+// DefaultForwardTimeout is the time Get waits for the answer of an owner
+// when the cache does not set ForwardTimeout
+const DefaultForwardTimeout = 200 * time.Millisecond
+
+// requestCounter generates the IDs of the requests sent to the owners
+var requestCounter atomic.Uint64
+
+// Owners returns the nodes that store a key in the partitioned mode,
+// every member when the cache is not partitioned
+func (c *Cache) Owners(key string) []uuid.UUID {
+	c.membersMutex.RLock()
+	ring := c.ring
+	c.membersMutex.RUnlock()
+	if ring == nil {
+		// the ring is built again only when the members change
+		c.membersMutex.Lock()
+		if c.ring == nil {
+			c.buildRing()
+		}
+		ring = c.ring
+		c.membersMutex.Unlock()
+	}
+	if !c.Partitioned {
+		return ring.owners(key, ring.nodes)
+	}
+	return ring.owners(key, c.getReplicationFactor())
+}
+
+// owns returns true when this node stores the key
+func (c *Cache) owns(key string) bool {
+	return !c.Partitioned || slices.Contains(c.Owners(key), c.node)
+}
+
+// forwardGet asks the owners of a key for its value,
+// it returns false when no owner has the value
+func (c *Cache) forwardGet(key string) (interface{}, bool) {
+	return c.ask(key, c.Owners(key))
+}
+
+// ask asks the nodes for the value of a key one after the other,
+// it returns false when no node has the value
+func (c *Cache) ask(key string, nodes []uuid.UUID) (interface{}, bool) {
+	if answer := c.askReply(key, nodes); answer != nil {
+		return answer.Value, true
+	}
+	return nil, false
+}
+
+// askReply asks the nodes for the value of a key one after the other and
+// returns the first reply with a value and its version, nil when no node has it
+func (c *Cache) askReply(key string, nodes []uuid.UUID) *message {
+	for _, owner := range nodes {
+		if owner == c.node {
+			continue
+		}
+		if answer := c.request(key, owner); answer != nil {
+			return answer
+		}
+	}
+	return nil
+}
+
+// askAny asks every node for the value of a key at once and returns the
+// first reply, nil when no node stores the key. The nodes do not fill it
+func (c *Cache) askAny(key string) *message {
+	return c.request(key, uuid.Nil)
+}
+
+// request sends a request for the value of a key to the target, every node
+// when it is nil, and waits for a reply with a value until the ForwardTimeout
+func (c *Cache) request(key string, target uuid.UUID) *message {
+	id := requestCounter.Add(1)
+	reply := make(chan *message, 1)
+	c.requestsMutex.Lock()
+	if c.requests == nil {
+		c.requests = make(map[uint64]chan *message)
+	}
+	c.requests[id] = reply
+	c.requestsMutex.Unlock()
+	defer func() {
+		c.requestsMutex.Lock()
+		delete(c.requests, id)
+		c.requestsMutex.Unlock()
+	}()
+
+	c.sendMessage(&message{CacheName: c.Name, Node: c.node, Key: key, Op: opGet, Target: target, Request: id})
+	timeout := time.After(c.getForwardTimeout())
+	for {
+		select {
+		case answer := <-reply:
+			if answer.Value != nil {
+				return answer
+			}
+			if target != uuid.Nil {
+				return nil
+			}
+		case <-timeout:
+			return nil
+		}
+	}
+}
+
+// replyGet answers the request of another node with the local value,
+// the requests sent to every node are answered only when the key is stored
+func (c *Cache) replyGet(request *message) {
+	ctx := c.getTracer().Extract(context.Background(), request.Trace)
+	ctx, span := c.getTracer().Start(ctx, SpanGet)
+	defer span.End()
+	span.SetAttributes(Attribute{Key: "dcache.cache", Value: c.Name}, Attribute{Key: "dcache.key", Value: request.Key},
+		Attribute{Key: "dcache.node", Value: request.Node.String()})
+	var value interface{}
+	if request.Target == uuid.Nil {
+		c.mutex.Lock()
+		stored, exists := c.getEntries().load(request.Key)
+		c.mutex.Unlock()
+		if !exists {
+			return
+		}
+		value = stored
+	} else {
+		value = c.getLocal(ctx, span, request.Key)
+	}
+	reply := c.newMessage(request.Key, value)
+	// the CRDT values are encoded like their deltas
+	_, reply.Merge = value.(CRDT)
+	c.mutex.Lock()
+	reply.Version = c.meta[request.Key].Version
+	c.mutex.Unlock()
+	reply.Op = opGetReply
+	reply.Target = request.Node
+	reply.Request = request.Request
+	c.sendMessage(reply)
+}
+
+// deliverReply delivers the answer of an owner to the waiting Get
+func (c *Cache) deliverReply(reply *message) {
+	c.requestsMutex.Lock()
+	waiting, ok := c.requests[reply.Request]
+	c.requestsMutex.Unlock()
+	if !ok {
+		return
+	}
+	select {
+	case waiting <- reply:
+	default:
+	}
+}
+
+func (c *Cache) getReplicationFactor() int {
+	if c.ReplicationFactor <= 0 {
+		return DefaultReplicationFactor
+	}
+	return c.ReplicationFactor
+}
+
+func (c *Cache) getForwardTimeout() time.Duration {
+	if c.ForwardTimeout <= 0 {
+		return DefaultForwardTimeout
+	}
+	return c.ForwardTimeout
+}
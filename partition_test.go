@@ -0,0 +1,137 @@
+package distributed_cache
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"net"
+	"slices"
+	"testing"
+	"time"
+)
+
+// ownedBy returns a key owned only by the given node
+func ownedBy(t *testing.T, c *Cache, node uuid.UUID) string {
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprint("key", i)
+		if owners := c.Owners(key); owners[0] == node {
+			return key
+		}
+	}
+	t.Fatalf("No key owned by %v", node)
+	return ""
+}
+
+func TestCache_PartitionedMembers(t *testing.T) {
+	partitioned := NewCache("partitionedCache", "255.255.255.255", ":12350")
+	defer partitioned.StopListener()
+	remote := uuid.New()
+	partitioned.handleMessage(&message{CacheName: "partitionedCache", Node: remote, Op: opHeartbeat,
+		from: net.ParseIP("10.0.0.2")})
+
+	members := partitioned.Members()
+	if len(members) != 2 {
+		t.Fatalf("Expected 2 members, got %v", members)
+	}
+	index := slices.IndexFunc(members, func(member Member) bool { return member.Node == remote })
+	if index == -1 || members[index].Address != "10.0.0.2:12350" {
+		t.Errorf("Expected the remote member at 10.0.0.2:12350, got %v", members)
+	}
+}
+
+func TestCache_PartitionedStoresOnlyOwnedKeys(t *testing.T) {
+	partitioned, err := New("partitionedCache", WithTransport("", ":12351"), WithPartitioning(1))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer partitioned.Close()
+	partitioned.ForwardTimeout = 50 * time.Millisecond
+	remote := uuid.New()
+	partitioned.handleMessage(&message{CacheName: "partitionedCache", Node: remote, Op: opHeartbeat})
+
+	local := ownedBy(t, partitioned, partitioned.node)
+	other := ownedBy(t, partitioned, remote)
+	partitioned.Set(local, "local")
+	partitioned.Set(other, "other")
+
+	if _, exists := partitioned.load(local); !exists {
+		t.Errorf("Expected the owned key to be stored")
+	}
+	if _, exists := partitioned.load(other); exists {
+		t.Errorf("Expected the key of another owner not to be stored")
+	}
+}
+
+func TestCache_PartitionedGetForwardsToOwner(t *testing.T) {
+	partitioned, err := New("partitionedCache", WithTransport("", ":12352"), WithPartitioning(1))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer partitioned.Close()
+	partitioned.ForwardTimeout = time.Second
+	remote := uuid.New()
+	partitioned.handleMessage(&message{CacheName: "partitionedCache", Node: remote, Op: opHeartbeat})
+	key := ownedBy(t, partitioned, remote)
+
+	// the owner answers the request
+	go func() {
+		for {
+			partitioned.requestsMutex.Lock()
+			var id uint64
+			for pending := range partitioned.requests {
+				id = pending
+			}
+			partitioned.requestsMutex.Unlock()
+			if id != 0 {
+				partitioned.handleMessage(&message{CacheName: "partitionedCache", Node: remote, Key: key,
+					Value: "remote", Op: opGetReply, Request: id})
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	if val := partitioned.Get(key); val != "remote" {
+		t.Errorf("Expected remote, got %v", val)
+	}
+}
+
+func TestCache_HeartbeatsOnlyWhenPartitioned(t *testing.T) {
+	replicated, err := New("heartbeatCache", WithTransport("", ":12513"))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer replicated.Close()
+	partitioned, err := New("heartbeatCache", WithTransport("", ":12514"), WithPartitioning(1))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer partitioned.Close()
+	time.Sleep(100 * time.Millisecond)
+
+	if sent := replicated.BatchStats().Messages; sent != 0 {
+		t.Errorf("Expected no heartbeats, got %v messages", sent)
+	}
+	if sent := partitioned.BatchStats().Messages; sent == 0 {
+		t.Errorf("Expected the heartbeats of the partitioned cache")
+	}
+}
+
+func TestCache_ReplyGetToEveryNodeDoesNotFill(t *testing.T) {
+	fills := 0
+	c, err := New("replyCache", WithTransport("", ":12515"), WithFiller(func(key string) (interface{}, error) {
+		fills++
+		return "filled", nil
+	}))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer c.Close()
+	c.replyGet(&message{CacheName: "replyCache", Node: uuid.New(), Key: "key", Op: opGet, Request: 1})
+
+	if _, exists := c.load("key"); exists || fills != 0 {
+		t.Errorf("Expected the key not to be filled, got %v fills", fills)
+	}
+	if sent := c.BatchStats().Messages; sent != 0 {
+		t.Errorf("Expected no reply, got %v messages", sent)
+	}
+}
@@ -0,0 +1,30 @@
+package distributed_cache
+
+// In this file, you can find the replication modes of the writes.
+// By default every Set sends the value to the other nodes. With
+// ReplicationInvalidate a Set only sends the key and the version of the write,
+// the other nodes drop their copy when it is older and fill it again with
+// their own Filler, so big values never travel between nodes.
+// The strong conditional writes are replicated in the same way, only the
+// CRDT deltas still carry their values.
+
+// ReplicationMode is what a Set sends to the other nodes
+type ReplicationMode uint8
+
+const (
+	ReplicationFull       ReplicationMode = iota // ReplicationFull sends the values
+	ReplicationInvalidate                        // ReplicationInvalidate sends only invalidations
+)
+
+// replicated returns the message to send for a write,
+// an invalidation when the cache uses ReplicationInvalidate or a Backend
+func (c *Cache) replicated(message *message) *message {
+	invalidate := c.Replication == ReplicationInvalidate || c.Backend != nil
+	if !invalidate || message.Value == nil {
+		return message
+	}
+	invalidation := *message
+	invalidation.Value = nil
+	invalidation.Op = opInvalidate
+	return &invalidation
+}
@@ -0,0 +1,39 @@
+package distributed_cache
+
+import (
+	"github.com/google/uuid"
+	"testing"
+)
+
+func TestCache_ReplicatedInvalidation(t *testing.T) {
+	c := &Cache{Replication: ReplicationInvalidate}
+	msg := &message{Key: "key", Value: "a big value", Version: 3}
+
+	invalidation := c.replicated(msg)
+	if invalidation.Op != opInvalidate || invalidation.Value != nil || invalidation.Version != 3 {
+		t.Errorf("Expected an invalidation of version 3, got %+v", invalidation)
+	}
+	if msg.Value == nil {
+		t.Errorf("replicated modified the original message")
+	}
+	if full := (&Cache{}).replicated(msg); full != msg {
+		t.Errorf("Expected the message with the value in ReplicationFull")
+	}
+}
+
+func TestCache_ApplyInvalidation(t *testing.T) {
+	c := &Cache{storage: make(map[string]interface{})}
+	node := uuid.New()
+	c.applyRemote(&message{Key: "key", Value: "value", Version: 2, Node: node})
+
+	// an invalidation of an older write does not drop the newer copy
+	c.applyRemote(&message{Key: "key", Op: opInvalidate, Version: 1, Node: node})
+	if _, exists := c.load("key"); !exists {
+		t.Errorf("Expected the key to be kept")
+	}
+
+	c.applyRemote(&message{Key: "key", Op: opInvalidate, Version: 3, Node: node})
+	if _, exists := c.load("key"); exists {
+		t.Errorf("Expected the key to be invalidated")
+	}
+}
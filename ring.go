@@ -0,0 +1,94 @@
+package distributed_cache
+
+// In this file, you can find the consistent hash ring used by the partitioned
+// mode. Every member is placed in the ring many times (virtual nodes) so the
+// keys are spread evenly, and when a member joins or leaves only the keys
+// next to its points change their owner.
+
+import (
+	"github.com/google/uuid"
+	"hash/fnv"
+	"slices"
+	"strconv"
+)
+
+// DefaultVirtualNodes is the number of points of each member in the ring
+// when the cache does not set VirtualNodes
+const DefaultVirtualNodes = 128
+
+// hashRing is a consistent hash ring of nodes
+type hashRing struct {
+	points []ringPoint // sorted by hash
+	nodes  int
+}
+
+// ringPoint is a virtual node of the ring
+type ringPoint struct {
+	hash uint64
+	node uuid.UUID
+}
+
+// newHashRing creates a ring with virtualNodes points for each node
+func newHashRing(nodes []uuid.UUID, virtualNodes int) *hashRing {
+	if virtualNodes <= 0 {
+		virtualNodes = DefaultVirtualNodes
+	}
+	ring := &hashRing{points: make([]ringPoint, 0, len(nodes)*virtualNodes), nodes: len(nodes)}
+	for _, node := range nodes {
+		for i := 0; i < virtualNodes; i++ {
+			ring.points = append(ring.points, ringPoint{hash: hashKey(node.String() + "#" + strconv.Itoa(i)), node: node})
+		}
+	}
+	slices.SortFunc(ring.points, func(a, b ringPoint) int {
+		if a.hash != b.hash {
+			if a.hash < b.hash {
+				return -1
+			}
+			return 1
+		}
+		return slices.Compare(a.node[:], b.node[:])
+	})
+	return ring
+}
+
+// owners returns the first n different nodes found clockwise from the key
+func (r *hashRing) owners(key string, n int) []uuid.UUID {
+	if len(r.points) == 0 {
+		return nil
+	}
+	if n > r.nodes {
+		n = r.nodes
+	}
+	hash := hashKey(key)
+	start, _ := slices.BinarySearchFunc(r.points, hash, func(point ringPoint, hash uint64) int {
+		if point.hash < hash {
+			return -1
+		}
+		if point.hash > hash {
+			return 1
+		}
+		return 0
+	})
+	owners := make([]uuid.UUID, 0, n)
+	for i := 0; i < len(r.points) && len(owners) < n; i++ {
+		node := r.points[(start+i)%len(r.points)].node
+		if !slices.Contains(owners, node) {
+			owners = append(owners, node)
+		}
+	}
+	return owners
+}
+
+// hashKey hashes a key with FNV-1a and mixes the bits
+// so similar keys are far from each other in the ring
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,51 @@
+package distributed_cache
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"testing"
+)
+
+func TestHashRing_Owners(t *testing.T) {
+	nodes := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+	ring := newHashRing(nodes, 0)
+
+	owners := ring.owners("key", 2)
+	if len(owners) != 2 || owners[0] == owners[1] {
+		t.Errorf("Expected 2 different owners, got %v", owners)
+	}
+	if all := ring.owners("key", 5); len(all) != 3 {
+		t.Errorf("Expected 3 owners, got %v", all)
+	}
+}
+
+func TestHashRing_Distribution(t *testing.T) {
+	nodes := []uuid.UUID{uuid.New(), uuid.New(), uuid.New(), uuid.New()}
+	ring := newHashRing(nodes, 0)
+	counts := make(map[uuid.UUID]int)
+	for i := 0; i < 10000; i++ {
+		counts[ring.owners(fmt.Sprint("key", i), 1)[0]]++
+	}
+	for node, count := range counts {
+		if count < 1500 || count > 3500 {
+			t.Errorf("Node %v owns %v of 10000 keys", node, count)
+		}
+	}
+}
+
+func TestHashRing_StableWhenNodeJoins(t *testing.T) {
+	nodes := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+	before := newHashRing(nodes, 0)
+	after := newHashRing(append(nodes, uuid.New()), 0)
+	moved := 0
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprint("key", i)
+		if before.owners(key, 1)[0] != after.owners(key, 1)[0] {
+			moved++
+		}
+	}
+	// about a quarter of the keys move to the new node
+	if moved > 4000 {
+		t.Errorf("Expected about 2500 keys to move, %v moved", moved)
+	}
+}
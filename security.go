@@ -0,0 +1,129 @@
+package distributed_cache
+
+// In this file, you can find the authentication of the datagrams.
+// When the nodes share a key (WithSecurity), every datagram is signed with
+// HMAC-SHA256 together with the time it was sent and the node that sent it.
+// The datagrams without a valid signature, sent more than MaxClockSkew ago or
+// already received are dropped, so only the nodes that know the key can write
+// to the cache and a captured datagram cannot be replayed. The clocks of the
+// nodes must be within MaxClockSkew. The datagrams are not encrypted, the
+// values can still be read by anyone in the network.
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MinKeySize is the minimum size of the shared key in bytes
+const MinKeySize = 16
+
+// MaxClockSkew is the maximum age of a signed datagram,
+// the clocks of the nodes must not differ by more than it
+const MaxClockSkew = 30 * time.Second
+
+// stampSize is the size of the time and the node signed with the datagrams
+const stampSize = 8 + len(uuid.UUID{})
+
+// signatureSize is the size of the stamp and the signature appended to the datagrams
+const signatureSize = stampSize + sha256.Size
+
+// ErrUnauthenticated is returned when a datagram does not have a valid signature
+var ErrUnauthenticated = errors.New("unauthenticated datagram")
+
+// ErrReplayed is returned, with ErrUnauthenticated, when a signed datagram
+// was already received or was sent more than MaxClockSkew ago
+var ErrReplayed = errors.New("replayed datagram")
+
+// stamps returns the times of the datagrams of a node, in nanoseconds,
+// increasing so no two datagrams of the node have the same
+type stamps struct {
+	last atomic.Int64
+}
+
+func (s *stamps) next(now time.Time) int64 {
+	for {
+		last := s.last.Load()
+		stamp := now.UnixNano()
+		if stamp <= last {
+			stamp = last + 1
+		}
+		if s.last.CompareAndSwap(last, stamp) {
+			return stamp
+		}
+	}
+}
+
+// sign returns the datagram with the stamp of the node and its signature appended
+func sign(key []byte, node uuid.UUID, stamp int64, datagram []byte) []byte {
+	signed := make([]byte, len(datagram), len(datagram)+signatureSize)
+	copy(signed, datagram)
+	signed = binary.BigEndian.AppendUint64(signed, uint64(stamp))
+	signed = append(signed, node[:]...)
+	mac := hmac.New(sha256.New, key)
+	mac.Write(signed)
+	return mac.Sum(signed)
+}
+
+// verify returns the datagram without its stamp and signature, and the node
+// and the time of the stamp. It returns ErrUnauthenticated when the signature
+// is missing or not valid
+func verify(key, signed []byte) ([]byte, uuid.UUID, int64, error) {
+	if len(signed) < signatureSize {
+		return nil, uuid.Nil, 0, ErrUnauthenticated
+	}
+	stamped, signature := signed[:len(signed)-sha256.Size], signed[len(signed)-sha256.Size:]
+	mac := hmac.New(sha256.New, key)
+	mac.Write(stamped)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, uuid.Nil, 0, ErrUnauthenticated
+	}
+	datagram, stamp := stamped[:len(stamped)-stampSize], stamped[len(stamped)-stampSize:]
+	node, _ := uuid.FromBytes(stamp[8:])
+	return datagram, node, int64(binary.BigEndian.Uint64(stamp[:8])), nil
+}
+
+// stamp is a datagram received from a node
+type stamp struct {
+	node uuid.UUID
+	time int64
+}
+
+// replayGuard remembers the datagrams received in the last MaxClockSkew
+type replayGuard struct {
+	mutex sync.Mutex
+	seen  map[stamp]bool
+	order []stamp // in the order they were received
+}
+
+// check returns an error when the datagram was sent more than MaxClockSkew
+// ago or in the future, or was already received, otherwise it remembers it
+func (g *replayGuard) check(node uuid.UUID, sent int64, now time.Time) error {
+	age := now.Sub(time.Unix(0, sent))
+	if age > MaxClockSkew || age < -MaxClockSkew {
+		return fmt.Errorf("%w: %w sent %v ago", ErrUnauthenticated, ErrReplayed, age)
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	oldest := now.Add(-MaxClockSkew).UnixNano()
+	for len(g.order) > 0 && g.order[0].time < oldest {
+		delete(g.seen, g.order[0])
+		g.order = g.order[1:]
+	}
+	received := stamp{node: node, time: sent}
+	if g.seen[received] {
+		return fmt.Errorf("%w: %w", ErrUnauthenticated, ErrReplayed)
+	}
+	if g.seen == nil {
+		g.seen = make(map[stamp]bool)
+	}
+	g.seen[received] = true
+	g.order = append(g.order, received)
+	return nil
+}
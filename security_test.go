@@ -0,0 +1,65 @@
+package distributed_cache
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func TestReceive_Security(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	node := uuid.New()
+	data, err := (&message{CacheName: "secureCache", Node: node, Key: "key", Value: "value"}).toUDP()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var replays replayGuard
+	now := time.Now().UnixNano()
+
+	signed := sign(key, node, now, data)
+	received, err := receive(createMockConnection(signed), key, &replays)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := received.decodeValue(nil); err != nil || received.Value != "value" {
+		t.Errorf("Expected value, got %v %v", received.Value, err)
+	}
+
+	if _, err := receive(createMockConnection(data), key, &replays); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Expected ErrUnauthenticated for an unsigned datagram, got %v", err)
+	}
+	other := sign([]byte("fedcba9876543210"), node, now+1, data)
+	if _, err := receive(createMockConnection(other), key, &replays); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Expected ErrUnauthenticated for another key, got %v", err)
+	}
+	if _, err := receive(createMockConnection(signed), key, &replays); !errors.Is(err, ErrReplayed) {
+		t.Errorf("Expected ErrReplayed for a datagram received twice, got %v", err)
+	}
+	old := sign(key, node, now-int64(2*MaxClockSkew), data)
+	if _, err := receive(createMockConnection(old), key, &replays); !errors.Is(err, ErrReplayed) {
+		t.Errorf("Expected ErrReplayed for an old datagram, got %v", err)
+	}
+	if _, err := receive(createMockConnection(sign(key, node, now+1, data)), key, &replays); err != nil {
+		t.Errorf("Expected the next datagram of the node, got %v", err)
+	}
+}
+
+func TestStamps_Increase(t *testing.T) {
+	var s stamps
+	now := time.Now()
+	if first, second := s.next(now), s.next(now); second <= first {
+		t.Errorf("Expected increasing stamps, got %d and %d", first, second)
+	}
+}
+
+func TestSender_Security(t *testing.T) {
+	c, err := New("secureCache", WithTransport("", ":12388"), WithSecurity([]byte("0123456789abcdef")))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer c.StopListener()
+	if c.sender.maxBytes != datagramSize-signatureSize {
+		t.Errorf("Expected room for the signature, got %d", c.sender.maxBytes)
+	}
+}
@@ -1,63 +1,404 @@
 package distributed_cache
 
-// In this file, you can find the methods sendDelete, sendSet and sendClean
+// In this file, you can find the methods sendMessage and sendClean
 // that are used to send messages to the other nodes.
 //Those methods are used internally
-//in the Cache struct to send messages to the other nodes
+//in the Cache struct to send messages to the other nodes.
+//The messages are queued in a long-lived sender that coalesces them
+//in batches that fit in a single datagram. The size of a batch is the sum of
+//the sizes of its messages, so each message is encoded once when it is added.
 import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
 	"log"
 	"net"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
-// sendDelete sends a delete message to the other nodes for a given key
-func (c *Cache) sendDelete(key string) {
-	message := &message{Key: key, Value: nil, CacheName: c.Name, Node: c.node}
-	sendMessage(c.Broadcast, c.Address, message)
+// datagramSize is the maximum size of a datagram read by the listener,
+// and the default maximum size of a batch
+const datagramSize = 1024
+
+// DefaultQueueSize is the number of messages that can wait to be sent,
+// when the queue is full new messages are dropped
+const DefaultQueueSize = 1024
+
+var (
+	errQueueFull     = errors.New("the queue of the sender is full")
+	errSenderStopped = errors.New("the sender is stopped")
+)
+
+// sendClean sends a sendClean message to the other nodes
+func (c *Cache) sendClean(ctx context.Context) {
+	c.sendMessageContext(ctx, c.newMessage(cleanMessageKey, nil))
 }
 
-// sendSet sends a set message to the other nodes for a given key and value
-func (c *Cache) sendSet(key string, value interface{}) {
-	message := &message{Key: key, Value: value, CacheName: c.Name, Node: c.node}
-	sendMessage(c.Broadcast, c.Address, message)
+// newMessage creates a message of this cache for a given key and value
+func (c *Cache) newMessage(key string, value interface{}) *message {
+	return &message{Key: key, Value: value, CacheName: c.Name, Node: c.node, codec: c.getCodec(),
+		compress: c.Compression, threshold: c.CompressionThreshold}
 }
 
-// sendClean sends a sendClean message to the other nodes
-func (c *Cache) sendClean() {
-	message := &message{Key: cleanMessageKey, Value: nil, CacheName: c.Name, Node: c.node}
-	sendMessage(c.Broadcast, c.Address, message)
-}
-
-// sendMessage sends a message to the other nodes,
-// the connection is created and closed in this function because is
-// a simple UDP message
-func sendMessage(broadcast, address string, message *message) {
-	conn := createSender(broadcast, address)
-	defer func(conn *net.UDPConn) {
-		err := conn.Close()
+// sendMessage queues messages to be sent to the other nodes,
+// the messages queued together are sent in as few datagrams as possible.
+// The sender is created with the first message and lives until the listener is stopped
+func (c *Cache) sendMessage(messages ...*message) {
+	c.sendMessageContext(context.Background(), messages...)
+}
+
+// getSender returns the sender of the cache, creating it the first time
+func (c *Cache) getSender() *sender {
+	c.senderOnce.Do(func() {
+		c.startSender(createSender(c.Broadcast, c.Address))
+	})
+	return c.sender
+}
+
+// startSender starts the sender of the cache with a connection
+func (c *Cache) startSender(conn uDPConnInterface) {
+	if c.MaxBatchBytes < 0 || c.MaxBatchBytes > datagramSize {
+		c.logError("invalid MaxBatchBytes", fmt.Errorf("%d is not between 0 and %d, using %d",
+			c.MaxBatchBytes, datagramSize, datagramSize))
+	}
+	c.sender = newSender(conn, c.Name, c.node, c.FlushInterval, c.MaxBatchBytes)
+	c.sender.logError = c.logError
+	if c.key != nil {
+		c.sender.key = c.key
+		c.sender.maxBytes -= signatureSize
+		c.sender.maxMessage -= signatureSize
+	}
+	go c.sender.run(c.context)
+}
+
+// BatchStats returns the metrics of the batches sent by the cache
+func (c *Cache) BatchStats() BatchStats {
+	return c.getSender().batchStats()
+}
+
+// BatchStats are the metrics of the batches sent to the other nodes
+type BatchStats struct {
+	Batches      uint64 // Datagrams sent
+	Messages     uint64 // Messages sent in those datagrams
+	Bytes        uint64 // Bytes sent
+	Dropped      uint64 // Messages dropped because the queue was full or could not be encoded
+	Errors       uint64 // Datagrams that could not be written
+	MaxBatchSize uint64 // Maximum number of messages sent in a single datagram
+}
+
+// AverageBatchSize returns the average number of messages per datagram
+func (b BatchStats) AverageBatchSize() float64 {
+	if b.Batches == 0 {
+		return 0
+	}
+	return float64(b.Messages) / float64(b.Batches)
+}
+
+// sender keeps the connection used to send the messages of a cache,
+// it coalesces the queued messages in batches of up to maxBytes,
+// waiting up to flushInterval for more messages before sending a batch
+type sender struct {
+	conn          uDPConnInterface
+	logError      func(msg string, err error, args ...any) // logs with the logger of the cache
+	key           []byte                                   // key to sign the datagrams, none when nil
+	stamps        stamps                                   // times signed with the datagrams
+	cacheName     string
+	node          uuid.UUID
+	queue         chan []*message
+	flushInterval time.Duration
+	maxBytes      int
+	maxMessage    int // maximum size of a message, the larger ones are dropped
+	pending       []envelope
+	pendingSent   []*sending // sends of the pending messages, in the same order
+	size          int        // estimated size of the pending batch
+	baseSize      int        // size of an empty batch
+	done          chan struct{}
+
+	batches      atomic.Uint64
+	messages     atomic.Uint64
+	bytes        atomic.Uint64
+	dropped      atomic.Uint64
+	errors       atomic.Uint64
+	maxBatchSize atomic.Uint64
+	mutex        sync.Mutex // serializes the writes to the connection
+}
+
+func newSender(conn uDPConnInterface, cacheName string, node uuid.UUID,
+	flushInterval time.Duration, maxBytes int) *sender {
+	if maxBytes <= 0 || maxBytes > datagramSize {
+		maxBytes = datagramSize
+	}
+	return &sender{
+		conn:          conn,
+		cacheName:     cacheName,
+		node:          node,
+		queue:         make(chan []*message, DefaultQueueSize),
+		flushInterval: flushInterval,
+		maxBytes:      maxBytes,
+		maxMessage:    datagramSize,
+		done:          make(chan struct{}),
+	}
+}
+
+// enqueue adds messages to the queue, the messages are dropped
+// when the queue is full or the sender is stopped
+func (s *sender) enqueue(ctx context.Context, messages ...*message) {
+	if ctx.Err() != nil {
+		s.drop(messages, errSenderStopped)
+		return
+	}
+	select {
+	case s.queue <- messages:
+	default:
+		s.drop(messages, errQueueFull)
+	}
+}
+
+// drop counts messages that are not sent and ends their sends with err
+func (s *sender) drop(messages []*message, err error) {
+	s.dropped.Add(uint64(len(messages)))
+	for _, message := range messages {
+		message.sent.done(err)
+	}
+}
+
+// run sends the queued messages until the context is done,
+// the messages that are still in the queue are sent before returning
+func (s *sender) run(ctx context.Context) {
+	defer close(s.done)
+	for {
+		select {
+		case <-ctx.Done():
+			s.drain()
+			s.flush()
+			if err := s.conn.Close(); err != nil {
+				s.error("closing the sender failed", err)
+			}
+			return
+		case messages := <-s.queue:
+			s.add(messages...)
+			s.linger(ctx)
+			s.flush()
+		}
+	}
+}
+
+// linger waits for more messages to add them to the pending batch,
+// without flushInterval it only takes the messages that are already queued
+func (s *sender) linger(ctx context.Context) {
+	if s.flushInterval <= 0 {
+		s.drain()
+		return
+	}
+	timer := time.NewTimer(s.flushInterval)
+	defer timer.Stop()
+	for {
+		select {
+		case messages := <-s.queue:
+			s.add(messages...)
+		case <-timer.C:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// drain adds all the queued messages to the pending batch
+func (s *sender) drain() {
+	for {
+		select {
+		case messages := <-s.queue:
+			s.add(messages...)
+		default:
+			return
+		}
+	}
+}
+
+// add adds messages to the pending batch
+func (s *sender) add(messages ...*message) {
+	for _, message := range messages {
+		s.addMessage(message)
+	}
+}
+
+// addMessage adds a message to the pending batch,
+// the pending batch is sent first when the message does not fit in it.
+// A message that does not fit in a datagram is dropped, the listeners would truncate it
+func (s *sender) addMessage(message *message) {
+	env, err := message.toEnvelope()
+	if err != nil {
+		s.error("encoding message failed", err, "key", message.Key, "op", message.Op.String())
+		s.dropped.Add(1)
+		message.sent.done(err)
+		return
+	}
+	data, err := s.encode([]envelope{env})
+	if err == nil && len(data) > s.maxMessage {
+		err = fmt.Errorf("message of %d bytes, the limit is %d", len(data), s.maxMessage)
+	}
+	var size int
+	if err == nil {
+		size, err = s.sizeInBatch(env)
+	}
+	if err != nil {
+		s.error("message dropped", err, "key", message.Key, "op", message.Op.String())
+		s.dropped.Add(1)
+		message.sent.done(err)
+		return
+	}
+	if s.size+size > s.maxBytes && len(s.pending) > 0 {
+		s.flush()
+	}
+	if len(s.pending) == 0 {
+		s.size = s.baseSize
+	}
+	s.pending = append(s.pending, env)
+	s.pendingSent = append(s.pendingSent, message.sent)
+	s.size += size
+}
+
+// sizeInBatch returns the size a message adds to a batch,
+// the size of a batch with only the message minus the size of an empty batch
+func (s *sender) sizeInBatch(env envelope) (int, error) {
+	if s.baseSize == 0 {
+		empty, err := s.encodeBatch(nil)
 		if err != nil {
-			log.Println(err)
+			return 0, err
 		}
-	}(conn)
-	data, err := message.toUDP()
+		s.baseSize = len(empty)
+	}
+	data, err := s.encodeBatch([]envelope{env})
+	if err != nil {
+		return 0, err
+	}
+	return len(data) - s.baseSize, nil
+}
+
+// error logs an error when the sender has a logger
+func (s *sender) error(msg string, err error, args ...any) {
+	if s.logError != nil {
+		s.logError(msg, err, args...)
+	}
+}
+
+// encode serializes a batch, a batch of a single message is sent
+// as a plain envelope to be understood by nodes that do not know batches
+func (s *sender) encode(batch []envelope) ([]byte, error) {
+	if len(batch) != 1 {
+		return s.encodeBatch(batch)
+	}
+	return encodeEnvelope(batch[0])
+}
+
+// encodeBatch serializes the messages as a batch of the node
+func (s *sender) encodeBatch(batch []envelope) ([]byte, error) {
+	env := envelope{CacheName: s.cacheName, Node: s.node, Batch: make([]envelope, len(batch))}
+	for i, item := range batch {
+		item.CacheName = ""
+		item.Node = uuid.Nil
+		env.Batch[i] = item
+	}
+	return encodeEnvelope(env)
+}
+
+// encodeEnvelope serializes an envelope with gob
+func encodeEnvelope(env envelope) ([]byte, error) {
+	var network bytes.Buffer
+	if err := gob.NewEncoder(&network).Encode(&env); err != nil {
+		return nil, err
+	}
+	return network.Bytes(), nil
+}
+
+// flush sends the pending batch
+func (s *sender) flush() {
+	pending, sent := s.pending, s.pendingSent
+	s.pending, s.pendingSent = nil, nil
+	s.size = 0
+	s.send(pending, sent)
+}
+
+// send encodes and sends a batch and ends the sends of its messages,
+// a batch larger than maxBytes because its size was underestimated is split in two
+func (s *sender) send(batch []envelope, sent []*sending) {
+	if len(batch) == 0 {
+		return
+	}
+	size := uint64(len(batch))
+	data, err := s.encode(batch)
 	if err != nil {
-		log.Println(err)
+		s.error("encoding batch failed", err, "messages", size)
+		s.dropped.Add(size)
+		endSends(sent, err)
+		return
+	}
+	if len(data) > s.maxBytes && len(batch) > 1 {
+		half := len(batch) / 2
+		s.send(batch[:half], sent[:half])
+		s.send(batch[half:], sent[half:])
 		return
 	}
-	_, err = conn.Write(data)
+	if s.key != nil {
+		data = sign(s.key, s.node, s.stamps.next(time.Now()), data)
+	}
+	s.mutex.Lock()
+	_, err = s.conn.Write(data)
+	s.mutex.Unlock()
+	endSends(sent, err)
 	if err != nil {
-		log.Println(err)
+		s.error("sending datagram failed", err, "messages", size)
+		s.errors.Add(1)
 		return
 	}
+	s.batches.Add(1)
+	s.messages.Add(size)
+	s.bytes.Add(uint64(len(data)))
+	for {
+		current := s.maxBatchSize.Load()
+		if size <= current || s.maxBatchSize.CompareAndSwap(current, size) {
+			break
+		}
+	}
+}
+
+// endSends ends the sends of the messages of a batch
+func endSends(sent []*sending, err error) {
+	for _, send := range sent {
+		send.done(err)
+	}
+}
+
+func (s *sender) batchStats() BatchStats {
+	return BatchStats{
+		Batches:      s.batches.Load(),
+		Messages:     s.messages.Load(),
+		Bytes:        s.bytes.Load(),
+		Dropped:      s.dropped.Load(),
+		Errors:       s.errors.Load(),
+		MaxBatchSize: s.maxBatchSize.Load(),
+	}
 }
 
 func createSender(broadcast, address string) *net.UDPConn {
-	udpAddr, err := net.ResolveUDPAddr("udp", broadcast+address)
+	conn, err := dialBroadcast(broadcast, address)
 	if err != nil {
 		log.Fatal(err)
 	}
-	conn, err := net.DialUDP("udp", nil, udpAddr)
+	return conn
+}
+
+// dialBroadcast creates the connection to send messages to the other nodes
+func dialBroadcast(broadcast, address string) (*net.UDPConn, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", broadcast+address)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	return conn
+	return net.DialUDP("udp", nil, udpAddr)
 }
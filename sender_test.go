@@ -0,0 +1,128 @@
+package distributed_cache
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingConn is a uDPConnInterface that keeps every datagram written
+type recordingConn struct {
+	MockUDPConn
+	mutex     sync.Mutex
+	datagrams [][]byte
+}
+
+func (r *recordingConn) Write(b []byte) (int, error) {
+	r.mutex.Lock()
+	r.datagrams = append(r.datagrams, append([]byte(nil), b...))
+	r.mutex.Unlock()
+	return len(b), nil
+}
+
+func (r *recordingConn) received() [][]byte {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.datagrams
+}
+
+func TestSender_Batch(t *testing.T) {
+	conn := &recordingConn{}
+	node := uuid.New()
+	s := newSender(conn, "testCache", node, 50*time.Millisecond, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for i := 0; i < 3; i++ {
+		s.enqueue(ctx, &message{CacheName: "testCache", Node: node, Key: fmt.Sprint("key", i), Value: "value"})
+	}
+	go s.run(ctx)
+	time.Sleep(200 * time.Millisecond)
+
+	datagrams := conn.received()
+	if len(datagrams) != 1 {
+		t.Fatalf("Expected 1 datagram, got %v", len(datagrams))
+	}
+	var received message
+	if err := received.decodeEnvelope(datagrams[0]); err != nil {
+		t.Fatalf("decodeEnvelope() error = %v", err)
+	}
+	messages := received.messages()
+	if len(messages) != 3 {
+		t.Fatalf("Expected 3 messages, got %v", len(messages))
+	}
+	for i, m := range messages {
+		if err := m.decodeValue(nil); err != nil {
+			t.Fatalf("decodeValue() error = %v", err)
+		}
+		if m.CacheName != "testCache" || m.Node != node || m.Key != fmt.Sprint("key", i) || m.Value != "value" {
+			t.Errorf("Unexpected message %v", m)
+		}
+	}
+	if stats := s.batchStats(); stats.Batches != 1 || stats.Messages != 3 || stats.MaxBatchSize != 3 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestSender_SplitsBatchesBySize(t *testing.T) {
+	conn := &recordingConn{}
+	node := uuid.New()
+	s := newSender(conn, "testCache", node, 50*time.Millisecond, 512)
+	ctx, cancel := context.WithCancel(context.Background())
+	for i := 0; i < 10; i++ {
+		s.enqueue(ctx, &message{CacheName: "testCache", Node: node, Key: fmt.Sprint("key", i), Value: "a longer value to fill the datagram"})
+	}
+	go s.run(ctx)
+	time.Sleep(200 * time.Millisecond)
+	cancel()
+	<-s.done
+
+	total := 0
+	for _, datagram := range conn.received() {
+		if len(datagram) > 512 {
+			t.Errorf("Datagram of %v bytes exceeds the limit", len(datagram))
+		}
+		var received message
+		if err := received.decodeEnvelope(datagram); err != nil {
+			t.Fatalf("decodeEnvelope() error = %v", err)
+		}
+		total += len(received.messages())
+	}
+	if total != 10 {
+		t.Errorf("Expected 10 messages, got %v", total)
+	}
+	if len(conn.received()) < 2 {
+		t.Errorf("Expected several datagrams, got %v", len(conn.received()))
+	}
+}
+
+func TestSender_DropsOversizedMessages(t *testing.T) {
+	conn := &recordingConn{}
+	node := uuid.New()
+	s := newSender(conn, "testCache", node, 0, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	s.enqueue(ctx, &message{CacheName: "testCache", Node: node, Key: "big", Value: strings.Repeat("a", 2*datagramSize)},
+		&message{CacheName: "testCache", Node: node, Key: "small", Value: "value"})
+	go s.run(ctx)
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	<-s.done
+
+	datagrams := conn.received()
+	if len(datagrams) != 1 {
+		t.Fatalf("Expected 1 datagram, got %v", len(datagrams))
+	}
+	var received message
+	if err := received.decodeEnvelope(datagrams[0]); err != nil || received.Key != "small" {
+		t.Errorf("Expected only the small message, got %v (%v)", received.Key, err)
+	}
+	if stats := s.batchStats(); stats.Dropped != 1 {
+		t.Errorf("Expected 1 dropped message, got %+v", stats)
+	}
+	if s := newSender(conn, "testCache", node, 0, 4*datagramSize); s.maxBytes != datagramSize {
+		t.Errorf("Expected the batches to be limited to a datagram, got %d", s.maxBytes)
+	}
+}
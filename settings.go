@@ -0,0 +1,116 @@
+package distributed_cache
+
+// In this file, you can find Resize and SetTTL, to change the MaxEntries
+// and the TTL of a running cache of any type that has them, so they work
+// with the caches of New and NewFromConfig too. Both take the mutex, so they
+// do not race with the other methods, and evict the entries that no longer
+// fit or that have expired at once, calling the RemoveHook. When
+// ShareSettings is set, the new setting is sent to the other nodes, that
+// apply it too.
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNoMaxEntries is returned by Resize in the cache types without MaxEntries
+var ErrNoMaxEntries = errors.New("the cache type has no maximum of entries, use MaxEntries")
+
+// resizable is implemented by the cache types with MaxEntries
+type resizable interface {
+	resize(maxEntries int)
+}
+
+// ttlSettable is implemented by the cache types with a TTL
+type ttlSettable interface {
+	setTTL(ttl time.Duration)
+}
+
+// Resize changes the maximum number of entries, unbounded when 0,
+// the least recently used entries are evicted until they fit.
+// It returns ErrNoMaxEntries when the cache type has no MaxEntries
+func (c *Cache) Resize(maxEntries int) error {
+	if maxEntries < 0 {
+		return &ConfigError{Field: "max entries", Reason: "must not be negative"}
+	}
+	c.mutex.Lock()
+	entries, ok := c.baseEntries().(resizable)
+	if !ok {
+		c.mutex.Unlock()
+		return ErrNoMaxEntries
+	}
+	entries.resize(maxEntries)
+	c.mutex.Unlock()
+	c.shareSetting(opResize, int64(maxEntries))
+	return nil
+}
+
+// resize changes MaxEntries and evicts the entries that do not fit,
+// the mutex must be locked
+func (c *LRUCache) resize(maxEntries int) {
+	c.MaxEntries = maxEntries
+	for maxEntries > 0 && len(c.queue) > maxEntries {
+		// removed with the cache type, so its own state and the log are updated
+		c.evict(c.queue[0])
+		c.getEntries().remove(c.queue[0])
+		c.counters.evictedCapacity.Add(1)
+	}
+}
+
+// SetTTL changes the time-to-live of the entries, the deadlines of the
+// current entries move by the difference and the expired ones are evicted.
+// It returns ErrNoExpiration when the cache type has no TTL
+func (c *Cache) SetTTL(ttl time.Duration) error {
+	if ttl <= 0 {
+		return &ConfigError{Field: "ttl", Reason: "must be positive"}
+	}
+	c.mutex.Lock()
+	entries, ok := c.baseEntries().(ttlSettable)
+	if !ok {
+		c.mutex.Unlock()
+		return ErrNoExpiration
+	}
+	entries.setTTL(ttl)
+	c.mutex.Unlock()
+	c.shareSetting(opSetTTL, int64(ttl))
+	return nil
+}
+
+// setTTL changes TTL and evicts the expired entries,
+// the deadlines set by Expire do not change, the mutex must be locked
+func (c *LRUCacheWithTTL) setTTL(ttl time.Duration) {
+	delta := ttl - c.TTL
+	c.TTL = ttl
+	for key, deadline := range c.ttlMap {
+		if !c.pinned[key] {
+			c.ttlMap[key] = deadline.Add(delta)
+		}
+	}
+	c.removeExpired()
+}
+
+// shareSetting sends a new setting to the other nodes when ShareSettings is set
+func (c *Cache) shareSetting(op operation, setting int64) {
+	if !c.ShareSettings {
+		return
+	}
+	c.sendMessageContext(context.Background(), &message{CacheName: c.Name, Node: c.node, Op: op, Setting: setting})
+}
+
+// applySetting applies a setting received from another node,
+// the cache types without the setting ignore it
+func (c *Cache) applySetting(message *message) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	switch message.Op {
+	case opResize:
+		if entries, ok := c.baseEntries().(resizable); ok && message.Setting >= 0 {
+			entries.resize(int(message.Setting))
+		}
+	case opSetTTL:
+		if entries, ok := c.baseEntries().(ttlSettable); ok && message.Setting > 0 {
+			entries.setTTL(time.Duration(message.Setting))
+		}
+	}
+}
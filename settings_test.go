@@ -0,0 +1,124 @@
+package distributed_cache
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLRUCache_Resize(t *testing.T) {
+	c := NewLRUCache("resizeCache", "255.255.255.255", ":12392", 4)
+	defer c.StopListener()
+	var mutex sync.Mutex
+	var removed []string
+	c.RemoveHook = func(key string, value interface{}) {
+		mutex.Lock()
+		removed = append(removed, key)
+		mutex.Unlock()
+	}
+	for _, key := range []string{"key1", "key2", "key3", "key4"} {
+		c.Set(key, "value")
+	}
+	if err := c.Resize(2); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if val := c.Get("key2"); val != nil {
+		t.Errorf("Expected key2 to be evicted, got %v", val)
+	}
+	if val := c.Get("key4"); val != "value" {
+		t.Errorf("Expected value, got %v", val)
+	}
+	if stats := c.Stats(); stats.Entries != 2 || stats.Evictions.Capacity != 2 {
+		t.Errorf("Expected 2 entries and 2 evictions, got %+v", stats)
+	}
+	time.Sleep(50 * time.Millisecond)
+	mutex.Lock()
+	if len(removed) != 2 {
+		t.Errorf("Expected the hook of the 2 evicted keys, got %v", removed)
+	}
+	mutex.Unlock()
+
+	var configErr *ConfigError
+	if err := c.Resize(-1); !errors.As(err, &configErr) {
+		t.Errorf("Expected a ConfigError, got %v", err)
+	}
+}
+
+func TestLRUCacheWithTTL_SetTTL(t *testing.T) {
+	c := NewLRUCacheWithTTL("setTTLCache", "255.255.255.255", ":12393", 10, time.Hour)
+	defer c.StopListener()
+	c.Set("key1", "value1")
+	time.Sleep(100 * time.Millisecond)
+	c.Set("key2", "value2")
+	if err := c.SetTTL(50 * time.Millisecond); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if val := c.Get("key1"); val != nil {
+		t.Errorf("Expected key1 to be expired, got %v", val)
+	}
+	if val := c.Get("key2"); val != "value2" {
+		t.Errorf("Expected value2, got %v", val)
+	}
+	if err := c.SetTTL(0); err == nil {
+		t.Errorf("Expected an error for a TTL of 0")
+	}
+}
+
+func TestCache_ApplySetting(t *testing.T) {
+	c := NewLRUCacheWithTTL("sharedSettingsCache", "255.255.255.255", ":12394", 10, time.Hour)
+	defer c.StopListener()
+	c.Set("key1", "value1")
+	c.Set("key2", "value2")
+
+	msg := &message{CacheName: "sharedSettingsCache", Node: c.node, Op: opResize, Setting: 1}
+	data, err := msg.toUDP()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	received := &message{}
+	if err := received.fromUDP(data); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	c.handleMessage(received)
+	c.handleMessage(&message{CacheName: "sharedSettingsCache", Node: c.node, Op: opSetTTL,
+		Setting: int64(time.Minute)})
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.MaxEntries != 1 || c.TTL != time.Minute || len(c.queue) != 1 {
+		t.Errorf("Expected 1 entry of 1m, got %d entries of %d and %v", len(c.queue), c.MaxEntries, c.TTL)
+	}
+}
+
+func TestCache_ResizeAndSetTTL(t *testing.T) {
+	c, err := New("settingsNew", WithTransport("", ":12506"), WithMaxEntries(4), WithTTL(time.Hour))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer c.StopListener()
+	for _, key := range []string{"key1", "key2", "key3"} {
+		c.Set(key, "value")
+	}
+	if err := c.Resize(1); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := c.SetTTL(time.Minute); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if stats := c.Stats(); stats.Entries != 1 {
+		t.Errorf("Expected 1 entry, got %d", stats.Entries)
+	}
+
+	unbounded, err := New("settingsUnbounded", WithTransport("", ":12507"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer unbounded.StopListener()
+	if err := unbounded.Resize(10); !errors.Is(err, ErrNoMaxEntries) {
+		t.Errorf("Expected ErrNoMaxEntries, got %v", err)
+	}
+	if err := unbounded.SetTTL(time.Minute); !errors.Is(err, ErrNoExpiration) {
+		t.Errorf("Expected ErrNoExpiration, got %v", err)
+	}
+}
@@ -0,0 +1,305 @@
+package distributed_cache
+
+// In this file, you can find the snapshots of the caches.
+// Snapshot writes the entries of any cache type in their LRU order with their
+// TTL deadlines and versions, Restore replaces the entries with the ones of a
+// snapshot, so a restarted node does not start empty. The values are encoded
+// with the codec of the cache (the CRDT values with the CRDT codec), so every
+// type stored in a gob cache without New must be registered with gob.Register.
+// EnableSnapshots restores a file and keeps writing it periodically, WithSnapshots
+// does it in New before the cache receives any change.
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshotVersion is the version of the snapshot format
+const snapshotVersion = 1
+
+// DefaultSnapshotInterval is the time between the snapshots of EnableSnapshots
+const DefaultSnapshotInterval = time.Minute
+
+// snapshot is the content of a snapshot
+type snapshot struct {
+	Version uint8
+	Codec   uint8
+	Clock   uint64
+	Entries []snapshotEntry // in LRU order, the least recently used first
+}
+
+// snapshotEntry is an entry of a snapshot
+type snapshotEntry struct {
+	Key      string
+	Value    []byte
+	Merge    bool      // the value is a CRDT encoded with the CRDT codec
+	Deadline time.Time // zero when the entry does not expire
+	Pinned   bool      // the deadline was set by Expire, it is not renewed
+	Meta     entryMeta
+}
+
+// ordered is implemented by the cache types that keep their keys in LRU order
+type ordered interface {
+	keys() []string // the least recently used first
+}
+
+// expiring is implemented by the cache types that expire their keys
+type expiring interface {
+	deadline(key string) time.Time
+	setDeadline(key string, deadline time.Time)
+	isPinned(key string) bool // the deadline was set by Expire
+}
+
+// keys returns the keys in LRU order, the mutex must be locked
+func (c *LRUCache) keys() []string {
+	return append([]string(nil), c.queue...)
+}
+
+// deadline returns the time a key expires, the mutex must be locked
+func (c *LRUCacheWithTTL) deadline(key string) time.Time {
+	return c.ttlMap[key]
+}
+
+// isPinned returns true when the deadline of a key was set by Expire,
+// the mutex must be locked
+func (c *LRUCacheWithTTL) isPinned(key string) bool {
+	return c.pinned[key]
+}
+
+// setDeadline sets the time a key expires, the mutex must be locked
+func (c *LRUCacheWithTTL) setDeadline(key string, deadline time.Time) {
+	if _, exists := c.storage[key]; exists {
+		c.ttlMap[key] = deadline
+	}
+}
+
+// Snapshot writes the entries of the cache to w
+func (c *Cache) Snapshot(w io.Writer) error {
+	c.mutex.Lock()
+	s, err := c.takeSnapshot()
+	c.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	return gob.NewEncoder(w).Encode(s)
+}
+
+// takeSnapshot returns the entries of the cache, the mutex must be locked
+func (c *Cache) takeSnapshot() (snapshot, error) {
+	entries := c.baseEntries()
+	keys := sortedKeys(c.storage)
+	if ordered, ok := entries.(ordered); ok {
+		keys = ordered.keys()
+	}
+	expiring, _ := entries.(expiring)
+	codec := c.getCodec()
+	s := snapshot{Version: snapshotVersion, Codec: codec.ID(), Clock: c.clock,
+		Entries: make([]snapshotEntry, 0, len(keys))}
+	now := time.Now()
+	for _, key := range keys {
+		entry := snapshotEntry{Key: key, Meta: c.meta[key]}
+		if expiring != nil {
+			entry.Deadline = expiring.deadline(key)
+			if now.After(entry.Deadline) {
+				continue
+			}
+			entry.Pinned = expiring.isPinned(key)
+		}
+		var err error
+		entry.Value, entry.Merge, err = encodeValue(codec, c.storage[key])
+		if err != nil {
+			return snapshot{}, fmt.Errorf("snapshot of %s: %w", key, err)
+		}
+		s.Entries = append(s.Entries, entry)
+	}
+	return s, nil
+}
+
+// encodeValue encodes a stored value with the codec, or the CRDT codec
+// when the value is a CRDT
+func encodeValue(codec Codec, value interface{}) ([]byte, bool, error) {
+	if _, merge := value.(CRDT); merge {
+		data, err := crdtCodec.Marshal(value)
+		return data, true, err
+	}
+	data, err := codec.Marshal(value)
+	return data, false, err
+}
+
+// decodeStored decodes a value encoded with encodeValue
+func decodeStored(codec Codec, data []byte, merge bool) (interface{}, error) {
+	if merge {
+		return crdtCodec.Unmarshal(data)
+	}
+	return codec.Unmarshal(data)
+}
+
+// Restore replaces the entries of the cache with the ones of a snapshot read
+// from r, the restored entries are not sent to the other nodes.
+// The expired entries are skipped and the cache type evicts the entries that do not fit
+func (c *Cache) Restore(r io.Reader) error {
+	s, values, err := readSnapshot(c.getCodec(), r)
+	if err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.restoreSnapshot(s, values)
+	return nil
+}
+
+// readSnapshot reads a snapshot encoded with codec and decodes its values
+func readSnapshot(codec Codec, r io.Reader) (snapshot, []interface{}, error) {
+	var s snapshot
+	if err := gob.NewDecoder(r).Decode(&s); err != nil {
+		return snapshot{}, nil, err
+	}
+	if s.Version != snapshotVersion {
+		return snapshot{}, nil, fmt.Errorf("unknown snapshot version %d", s.Version)
+	}
+	if s.Codec != codec.ID() {
+		return snapshot{}, nil, fmt.Errorf("%w: snapshot encoded with %s, cache uses %s",
+			ErrCodecMismatch, codecName(s.Codec), codec.Name())
+	}
+	values := make([]interface{}, len(s.Entries))
+	for i, entry := range s.Entries {
+		var err error
+		values[i], err = decodeStored(codec, entry.Value, entry.Merge)
+		if err != nil {
+			return snapshot{}, nil, fmt.Errorf("restore of %s: %w", entry.Key, err)
+		}
+	}
+	return s, values, nil
+}
+
+// readSnapshotFile reads the snapshot of path
+func readSnapshotFile(codec Codec, path string) (snapshot, []interface{}, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return snapshot{}, nil, err
+	}
+	defer file.Close()
+	return readSnapshot(codec, file)
+}
+
+// restoreSnapshot replaces the entries with the ones of a snapshot and its
+// decoded values, the mutex must be locked
+func (c *Cache) restoreSnapshot(s snapshot, values []interface{}) {
+	entries := c.getEntries()
+	entries.removeAll()
+	expiring, _ := c.baseEntries().(expiring)
+	now := time.Now()
+	for i, entry := range s.Entries {
+		if !entry.Deadline.IsZero() && now.After(entry.Deadline) {
+			continue
+		}
+		entries.store(entry.Key, values[i])
+		if _, exists := c.storage[entry.Key]; !exists {
+			continue
+		}
+		if entry.Meta.Version != 0 {
+			c.setMeta(entry.Key, entry.Meta)
+		}
+		if expirable, ok := c.baseEntries().(expirable); ok && entry.Pinned {
+			expirable.expire(entry.Key, entry.Deadline)
+			if c.wal != nil {
+				c.appendRecord(record{op: recordExpire, key: entry.Key, deadline: entry.Deadline})
+			}
+		} else if expiring != nil && !entry.Deadline.IsZero() {
+			expiring.setDeadline(entry.Key, entry.Deadline)
+		}
+	}
+	c.observe(s.Clock)
+}
+
+// EnableSnapshots restores the snapshot of path when it exists and writes
+// a new one every interval (DefaultSnapshotInterval when 0) and when the cache
+// is stopped. It must be called right after creating the cache
+func (c *Cache) EnableSnapshots(path string, interval time.Duration) error {
+	if err := c.restoreIfExists(path); err != nil {
+		return err
+	}
+	c.startSnapshots(path, interval)
+	return nil
+}
+
+// restoreIfExists restores the snapshot of path when it exists
+func (c *Cache) restoreIfExists(path string) error {
+	if err := c.RestoreFile(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// startSnapshots writes a snapshot to path every interval and when the cache is stopped
+func (c *Cache) startSnapshots(path string, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultSnapshotInterval
+	}
+	c.snapshotDone = make(chan struct{})
+	go c.runSnapshots(c.context, path, interval)
+}
+
+// runSnapshots writes the snapshots of EnableSnapshots until the context is done
+func (c *Cache) runSnapshots(ctx context.Context, path string, interval time.Duration) {
+	defer close(c.snapshotDone)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := c.SnapshotFile(path); err != nil {
+				c.logError("snapshot failed", err, "path", path)
+			}
+			return
+		case <-ticker.C:
+			if err := c.SnapshotFile(path); err != nil {
+				c.logError("snapshot failed", err, "path", path)
+			}
+		}
+	}
+}
+
+// SnapshotFile writes a snapshot to path atomically
+func (c *Cache) SnapshotFile(path string) error {
+	return writeFileAtomic(path, c.Snapshot)
+}
+
+// writeFileAtomic writes a file to a temporary file that is renamed to path
+func writeFileAtomic(path string, write func(w io.Writer) error) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// RestoreFile restores the snapshot of path
+func (c *Cache) RestoreFile(path string) error {
+	s, values, err := readSnapshotFile(c.getCodec(), path)
+	if err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.restoreSnapshot(s, values)
+	return nil
+}
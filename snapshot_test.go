@@ -0,0 +1,157 @@
+package distributed_cache
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLRUCache_SnapshotRestore(t *testing.T) {
+	source := NewLRUCache("snapshotLRU", "255.255.255.255", ":12358", 3)
+	defer source.StopListener()
+	source.Set("key1", "value1")
+	source.Set("key2", "value2")
+	source.Set("key3", "value3")
+	source.Set("key1", "value1")
+	source.Incr("counter", 2)
+
+	var buffer bytes.Buffer
+	if err := source.Snapshot(&buffer); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	target := NewLRUCache("snapshotLRU", "255.255.255.255", ":12359", 3)
+	defer target.StopListener()
+	if err := target.Restore(&buffer); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := []string{"key3", "key1", "counter"}
+	if !slices.Equal(target.queue, expected) {
+		t.Errorf("Expected queue %v, got %v", expected, target.queue)
+	}
+	if val := target.Get("key1"); val != "value1" {
+		t.Errorf("Expected value1, got %v", val)
+	}
+	if val := target.Counter("counter"); val != 2 {
+		t.Errorf("Expected counter 2, got %v", val)
+	}
+}
+
+func TestLRUCacheWithTTL_SnapshotRestore(t *testing.T) {
+	source := NewLRUCacheWithTTL("snapshotTTL", "255.255.255.255", ":12360", 10, time.Hour)
+	defer source.StopListener()
+	source.Set("key1", "value1")
+	source.Set("expired", "value")
+	deadline := time.Now().Add(time.Minute).Round(0)
+	source.mutex.Lock()
+	source.ttlMap["key1"] = deadline
+	source.ttlMap["expired"] = time.Now().Add(-time.Second)
+	source.mutex.Unlock()
+
+	var buffer bytes.Buffer
+	if err := source.Snapshot(&buffer); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	target := NewLRUCacheWithTTL("snapshotTTL", "255.255.255.255", ":12361", 10, time.Hour)
+	defer target.StopListener()
+	if err := target.Restore(&buffer); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !target.ttlMap["key1"].Equal(deadline) {
+		t.Errorf("Expected deadline %v, got %v", deadline, target.ttlMap["key1"])
+	}
+	if _, exists := target.storage["expired"]; exists {
+		t.Errorf("Expected the expired entry not to be restored")
+	}
+}
+
+func TestCache_RestoreCodecMismatch(t *testing.T) {
+	source := NewCache("snapshotCodec", "255.255.255.255", ":12362")
+	defer source.StopListener()
+	source.Set("key", "value")
+	var buffer bytes.Buffer
+	if err := source.Snapshot(&buffer); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	target := NewCache("snapshotCodec", "255.255.255.255", ":12363")
+	defer target.StopListener()
+	target.Codec = JSONCodec{}
+	if err := target.Restore(&buffer); !errors.Is(err, ErrCodecMismatch) {
+		t.Errorf("Expected ErrCodecMismatch, got %v", err)
+	}
+}
+
+func TestCache_EnableSnapshots(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+	first := NewCache("snapshotFile", "255.255.255.255", ":12364")
+	if err := first.EnableSnapshots(path, time.Hour); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	first.Set("key", "value")
+	if err := first.Close(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	second := NewCache("snapshotFile", "255.255.255.255", ":12365")
+	defer second.Close()
+	if err := second.EnableSnapshots(path, time.Hour); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if val := second.Get("key"); val != "value" {
+		t.Errorf("Expected value from the snapshot, got %v", val)
+	}
+}
+
+func TestCache_WithSnapshots(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+	first, err := New("withSnapshots", WithTransport("", ":12509"), WithTTL(time.Hour),
+		WithSnapshots(path, time.Hour))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	first.Set("pinned", "value")
+	if _, err := first.Expire("pinned", time.Minute); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := first.Close(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	second, err := New("withSnapshots", WithTransport("", ":12510"), WithTTL(time.Hour),
+		WithSnapshots(path, time.Hour))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer second.Close()
+	if val := second.Get("pinned"); val != "value" {
+		t.Errorf("Expected value from the snapshot, got %v", val)
+	}
+	// the deadline set by Expire is still not renewed by the reads
+	second.mutex.Lock()
+	expiring := second.baseEntries().(expiring)
+	pinned, deadline := expiring.isPinned("pinned"), expiring.deadline("pinned")
+	second.mutex.Unlock()
+	if !pinned || time.Until(deadline) > time.Minute {
+		t.Errorf("Expected the pinned deadline, got %v %v", pinned, deadline)
+	}
+}
+
+func TestCache_WithSnapshotsFailedStart(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cache.snapshot")
+	notADir := filepath.Join(dir, "file")
+	if err := os.WriteFile(notADir, nil, 0o644); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := New("withSnapshots", WithTransport("", ":12534"), WithSnapshots(path, time.Hour),
+		WithLog(notADir, LogOptions{})); err == nil {
+		t.Fatalf("Expected an error enabling the log")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected no snapshot of a cache that did not start, got %v", err)
+	}
+}
@@ -0,0 +1,229 @@
+package distributed_cache
+
+// In this file, you can find the statistics of the caches.
+// The counters are updated by Get, GetMany, the fillers, the evictions of the
+// cache types, the sender and the listener, and Stats returns them together
+// with the number of entries and their size, that is kept up to date by the
+// writes of the entries. ResetStats starts them again.
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FillLatencyBuckets are the upper bounds of the buckets of the fill latency histogram
+var FillLatencyBuckets = []time.Duration{
+	time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 500 * time.Millisecond, time.Second, 5 * time.Second,
+}
+
+// Stats are the statistics of a cache since it was created or ResetStats was called
+type Stats struct {
+	Hits        uint64    // Keys found in the cache
+	Misses      uint64    // Keys not found in the cache
+	Fills       uint64    // Calls to Filler and BatchFiller
+	FillErrors  uint64    // Calls to Filler and BatchFiller that returned an error
+	FillLatency Histogram // Latency of the calls to Filler and BatchFiller
+	Evictions   Evictions
+	Entries     int // Entries in the cache
+	Bytes       int // Approximate size of the keys and values in the cache
+	Network     NetworkStats
+}
+
+// HitRatio returns the hits divided by the lookups
+func (s Stats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// Evictions are the entries removed by the cache itself, by reason
+type Evictions struct {
+	Capacity uint64 // Least recently used entries removed because the cache was full
+	Expired  uint64 // Entries removed because their TTL expired
+}
+
+// NetworkStats are the counters of the messages exchanged with the other nodes
+type NetworkStats struct {
+	Sent          uint64 // Messages sent
+	Dropped       uint64 // Messages that could not be sent
+	Received      uint64 // Messages received for this cache
+	DecodeErrors  uint64 // Datagrams or values that could not be decoded
+	IgnoredByName uint64 // Messages received for other caches
+}
+
+// Histogram counts observations in buckets, Counts[i] are the observations
+// lower or equal than Bounds[i] and the last count the ones over every bound
+type Histogram struct {
+	Bounds []time.Duration
+	Counts []uint64
+	Count  uint64
+	Sum    time.Duration
+}
+
+// histogram is a Histogram safe for concurrent use
+type histogram struct {
+	mutex  sync.Mutex
+	counts []uint64
+	count  uint64
+	sum    time.Duration
+}
+
+func (h *histogram) observe(value time.Duration) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.counts == nil {
+		h.counts = make([]uint64, len(FillLatencyBuckets)+1)
+	}
+	i := 0
+	for i < len(FillLatencyBuckets) && value > FillLatencyBuckets[i] {
+		i++
+	}
+	h.counts[i]++
+	h.count++
+	h.sum += value
+}
+
+func (h *histogram) snapshot() Histogram {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	counts := make([]uint64, len(FillLatencyBuckets)+1)
+	copy(counts, h.counts)
+	return Histogram{Bounds: FillLatencyBuckets, Counts: counts, Count: h.count, Sum: h.sum}
+}
+
+func (h *histogram) reset() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.counts, h.count, h.sum = nil, 0, 0
+}
+
+// counters are the counters behind Stats
+type counters struct {
+	hits            atomic.Uint64
+	misses          atomic.Uint64
+	fills           atomic.Uint64
+	fillErrors      atomic.Uint64
+	evictedCapacity atomic.Uint64
+	evictedExpired  atomic.Uint64
+	received        atomic.Uint64
+	decodeErrors    atomic.Uint64
+	ignored         atomic.Uint64
+	fillLatency     histogram
+	// BatchStats of the sender when ResetStats was called
+	baseMutex sync.Mutex
+	base      BatchStats
+}
+
+// getCounters returns the counters of the cache, used by the listener
+func (c *Cache) getCounters() *counters {
+	return &c.counters
+}
+
+// lookup counts a hit or a miss
+func (c *Cache) lookup(exists bool) {
+	if exists {
+		c.counters.hits.Add(1)
+	} else {
+		c.counters.misses.Add(1)
+	}
+}
+
+// observeFill counts a call to a filler that started at start
+func (c *Cache) observeFill(start time.Time, err error) {
+	c.counters.fills.Add(1)
+	if err != nil {
+		c.counters.fillErrors.Add(1)
+	}
+	c.counters.fillLatency.observe(time.Since(start))
+}
+
+// Stats returns the statistics of the cache
+func (c *Cache) Stats() Stats {
+	batches := c.BatchStats()
+	c.counters.baseMutex.Lock()
+	base := c.counters.base
+	c.counters.baseMutex.Unlock()
+	stats := Stats{
+		Hits:        c.counters.hits.Load(),
+		Misses:      c.counters.misses.Load(),
+		Fills:       c.counters.fills.Load(),
+		FillErrors:  c.counters.fillErrors.Load(),
+		FillLatency: c.counters.fillLatency.snapshot(),
+		Evictions: Evictions{
+			Capacity: c.counters.evictedCapacity.Load(),
+			Expired:  c.counters.evictedExpired.Load(),
+		},
+		Network: NetworkStats{
+			Sent:          batches.Messages - base.Messages,
+			Dropped:       batches.Dropped - base.Dropped,
+			Received:      c.counters.received.Load(),
+			DecodeErrors:  c.counters.decodeErrors.Load(),
+			IgnoredByName: c.counters.ignored.Load(),
+		},
+	}
+	c.mutex.Lock()
+	stats.Entries = len(c.storage)
+	stats.Bytes = c.bytes
+	c.mutex.Unlock()
+	return stats
+}
+
+// putStorage stores a value in the storage and updates its size,
+// the mutex must be locked
+func (c *Cache) putStorage(key string, value interface{}) {
+	if c.sizes == nil {
+		c.sizes = make(map[string]int)
+	}
+	size := len(key) + sizeOf(c.getCodec(), value)
+	c.bytes += size - c.sizes[key]
+	c.sizes[key] = size
+	c.storage[key] = value
+}
+
+// deleteStorage deletes a key from the storage and its size,
+// the mutex must be locked
+func (c *Cache) deleteStorage(key string) {
+	c.bytes -= c.sizes[key]
+	delete(c.sizes, key)
+	delete(c.storage, key)
+}
+
+// resetStorage empties the storage, the mutex must be locked
+func (c *Cache) resetStorage() {
+	c.storage = make(map[string]interface{})
+	c.sizes = nil
+	c.bytes = 0
+}
+
+// ResetStats sets the counters of Stats to zero
+func (c *Cache) ResetStats() {
+	batches := c.BatchStats()
+	c.counters.baseMutex.Lock()
+	c.counters.base = batches
+	c.counters.baseMutex.Unlock()
+	for _, counter := range []*atomic.Uint64{&c.counters.hits, &c.counters.misses, &c.counters.fills,
+		&c.counters.fillErrors, &c.counters.evictedCapacity, &c.counters.evictedExpired,
+		&c.counters.received, &c.counters.decodeErrors, &c.counters.ignored} {
+		counter.Store(0)
+	}
+	c.counters.fillLatency.reset()
+}
+
+// sizeOf returns the approximate size of a value, its encoded size
+// when it is not a string or a byte slice
+func sizeOf(codec Codec, value interface{}) int {
+	switch v := value.(type) {
+	case string:
+		return len(v)
+	case []byte:
+		return len(v)
+	}
+	data, _, err := encodeValue(codec, value)
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
@@ -0,0 +1,102 @@
+package distributed_cache
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestCache_Stats(t *testing.T) {
+	c := NewLRUCache("statsCache", "255.255.255.255", ":12376", 2)
+	defer c.StopListener()
+	c.Filler = func(key string) (interface{}, error) {
+		if key == "bad" {
+			return nil, errors.New("fill failed")
+		}
+		return "filled", nil
+	}
+	c.Set("key1", "value1")
+	c.Get("key1")
+	c.Get("key2")
+	c.Get("bad")
+	c.Set("key3", "value3")
+
+	stats := c.Stats()
+	if stats.Hits != 1 || stats.Misses != 2 {
+		t.Errorf("Expected 1 hit and 2 misses, got %d and %d", stats.Hits, stats.Misses)
+	}
+	if stats.HitRatio() != 1.0/3 {
+		t.Errorf("Expected a hit ratio of 1/3, got %v", stats.HitRatio())
+	}
+	if stats.Fills != 2 || stats.FillErrors != 1 || stats.FillLatency.Count != 2 {
+		t.Errorf("Expected 2 fills and 1 error, got %+v", stats)
+	}
+	if stats.Evictions.Capacity != 1 {
+		t.Errorf("Expected 1 eviction, got %d", stats.Evictions.Capacity)
+	}
+	if stats.Entries != 2 || stats.Bytes != len("key2filledkey3value3") {
+		t.Errorf("Expected 2 entries of %d bytes, got %d of %d", len("key2filledkey3value3"),
+			stats.Entries, stats.Bytes)
+	}
+
+	c.ResetStats()
+	stats = c.Stats()
+	if stats.Hits != 0 || stats.Misses != 0 || stats.Fills != 0 || stats.FillLatency.Count != 0 ||
+		stats.Evictions.Capacity != 0 || stats.Network.Sent != 0 {
+		t.Errorf("Expected the counters to be reset, got %+v", stats)
+	}
+	if stats.Entries != 2 {
+		t.Errorf("Expected the entries not to be reset, got %d", stats.Entries)
+	}
+
+	// the size follows the overwrites, the deletes and the cleans
+	c.Set("key3", "v3")
+	c.Delete("key2")
+	if stats := c.Stats(); stats.Bytes != len("key3v3") {
+		t.Errorf("Expected %d bytes, got %d", len("key3v3"), stats.Bytes)
+	}
+	c.Clean()
+	if stats := c.Stats(); stats.Bytes != 0 {
+		t.Errorf("Expected 0 bytes after clean, got %d", stats.Bytes)
+	}
+}
+
+func TestLRUCacheWithTTL_StatsExpired(t *testing.T) {
+	c := NewLRUCacheWithTTL("statsTTL", "255.255.255.255", ":12377", 10, time.Hour)
+	defer c.StopListener()
+	c.Set("key", "value")
+	c.mutex.Lock()
+	c.ttlMap["key"] = time.Now().Add(-time.Second)
+	c.mutex.Unlock()
+	c.Get("key")
+	if stats := c.Stats(); stats.Evictions.Expired != 1 {
+		t.Errorf("Expected 1 expired entry, got %d", stats.Evictions.Expired)
+	}
+}
+
+func TestCache_StatsNetwork(t *testing.T) {
+	c := NewCache("statsNetwork", "255.255.255.255", ":12378")
+	defer c.StopListener()
+	time.Sleep(100 * time.Millisecond) // waits for the listener
+	conn, err := net.Dial("udp", "127.0.0.1:12378")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer conn.Close()
+	for _, name := range []string{"statsNetwork", "otherCache"} {
+		data, err := (&message{CacheName: name, Node: uuid.New(), Key: "key", Value: "value"}).toUDP()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		conn.Write(data)
+	}
+	conn.Write([]byte("not an envelope"))
+	time.Sleep(100 * time.Millisecond)
+
+	network := c.Stats().Network
+	if network.Received != 1 || network.IgnoredByName != 1 || network.DecodeErrors != 1 {
+		t.Errorf("Expected 1 received, 1 ignored and 1 decode error, got %+v", network)
+	}
+}
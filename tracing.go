@@ -0,0 +1,153 @@
+package distributed_cache
+
+// In this file, you can find the tracing hooks of the caches.
+// A Tracer creates spans for the writes, Get, the Filler calls, the messages
+// sent to the other nodes and the messages applied from them. The span of a
+// send ends when its messages are written to the network. The trace context of a write
+// travels in the envelope, so the span that applies it in a remote node is a
+// child of the span of the write. Without a Tracer no spans are created.
+// The dcacheotel module has an adapter for OpenTelemetry.
+
+import (
+	"context"
+	"time"
+)
+
+// Span names
+const (
+	SpanGet    = "dcache.Get"
+	SpanSet    = "dcache.Set"
+	SpanDelete = "dcache.Delete"
+	SpanClean  = "dcache.Clean"
+	SpanMerge  = "dcache.Merge"
+	SpanFiller = "dcache.Filler"
+	SpanSend   = "dcache.send"
+	SpanApply  = "dcache.apply"
+)
+
+// Tracer creates the spans of a cache
+type Tracer interface {
+	// Start starts a span that is a child of the span of ctx
+	Start(ctx context.Context, name string) (context.Context, Span)
+	// Inject returns the trace context of ctx to send it to the other nodes
+	Inject(ctx context.Context) map[string]string
+	// Extract returns a context with the trace context received from another node
+	Extract(ctx context.Context, carrier map[string]string) context.Context
+}
+
+// Span is an operation traced by a Tracer
+type Span interface {
+	SetAttributes(attributes ...Attribute)
+	RecordError(err error)
+	End()
+}
+
+// Attribute is a key and a value added to a span
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// NoopTracer is a Tracer that creates no spans, it is used when Tracer is nil
+type NoopTracer struct{}
+
+func (NoopTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+func (NoopTracer) Inject(ctx context.Context) map[string]string {
+	return nil
+}
+
+func (NoopTracer) Extract(ctx context.Context, carrier map[string]string) context.Context {
+	return ctx
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(attributes ...Attribute) {}
+
+func (noopSpan) RecordError(err error) {}
+
+func (noopSpan) End() {}
+
+func (c *Cache) getTracer() Tracer {
+	if c.Tracer == nil {
+		return NoopTracer{}
+	}
+	return c.Tracer
+}
+
+// lockTraced locks the mutex and records the time it waited in the span
+func (c *Cache) lockTraced(span Span) {
+	start := time.Now()
+	c.mutex.Lock()
+	span.SetAttributes(Attribute{Key: "dcache.lock_wait", Value: time.Since(start)})
+}
+
+// startWrite starts the span of a write of a key
+func (c *Cache) startWrite(ctx context.Context, name, key string) (context.Context, Span) {
+	ctx, span := c.getTracer().Start(ctx, name)
+	span.SetAttributes(Attribute{Key: "dcache.cache", Value: c.Name}, Attribute{Key: "dcache.key", Value: key})
+	return ctx, span
+}
+
+// sendMessageContext queues messages with the trace context of ctx,
+// the span of the send ends when the sender has written or dropped them
+func (c *Cache) sendMessageContext(ctx context.Context, messages ...*message) {
+	if len(messages) == 0 {
+		return
+	}
+	if c.Tracer == nil {
+		c.getSender().enqueue(c.context, messages...)
+		return
+	}
+	ctx, span := c.Tracer.Start(ctx, SpanSend)
+	span.SetAttributes(Attribute{Key: "dcache.cache", Value: c.Name},
+		Attribute{Key: "dcache.messages", Value: len(messages)})
+	trace := c.Tracer.Inject(ctx)
+	sent := &sending{span: span, left: len(messages)}
+	for _, message := range messages {
+		message.Trace = trace
+		message.sent = sent
+	}
+	c.getSender().enqueue(c.context, messages...)
+}
+
+// sending is the span of messages queued together,
+// it ends when all of them were written or dropped by the sender
+type sending struct {
+	span Span
+	left int
+	err  error
+}
+
+// done ends the span after the last message, nil when the send is not traced.
+// It is called by the sender, or by enqueue when the messages are not queued
+func (s *sending) done(err error) {
+	if s == nil {
+		return
+	}
+	if err != nil && s.err == nil {
+		s.err = err
+	}
+	s.left--
+	if s.left == 0 {
+		if s.err != nil {
+			s.span.RecordError(s.err)
+		}
+		s.span.End()
+	}
+}
+
+// applyTraced applies a message received from another node
+// in a span that is a child of the span of the write
+func (c *Cache) applyTraced(message *message, apply func()) {
+	ctx := c.getTracer().Extract(context.Background(), message.Trace)
+	_, span := c.getTracer().Start(ctx, SpanApply)
+	defer span.End()
+	span.SetAttributes(Attribute{Key: "dcache.cache", Value: c.Name},
+		Attribute{Key: "dcache.key", Value: message.Key},
+		Attribute{Key: "dcache.node", Value: message.Node.String()})
+	apply()
+}
@@ -0,0 +1,169 @@
+package distributed_cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type traceKey struct{}
+
+// recordedSpan is a span of the recordingTracer
+type recordedSpan struct {
+	name       string
+	parent     string
+	attributes map[string]interface{}
+	err        error
+	ended      atomic.Bool
+}
+
+func (s *recordedSpan) SetAttributes(attributes ...Attribute) {
+	for _, attribute := range attributes {
+		s.attributes[attribute.Key] = attribute.Value
+	}
+}
+
+func (s *recordedSpan) RecordError(err error) {
+	s.err = err
+}
+
+func (s *recordedSpan) End() {
+	s.ended.Store(true)
+}
+
+// recordingTracer records the spans and propagates the name of the parent span
+type recordingTracer struct {
+	mutex sync.Mutex
+	spans []*recordedSpan
+}
+
+func (r *recordingTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	parent, _ := ctx.Value(traceKey{}).(string)
+	span := &recordedSpan{name: name, parent: parent, attributes: make(map[string]interface{})}
+	r.mutex.Lock()
+	r.spans = append(r.spans, span)
+	r.mutex.Unlock()
+	return context.WithValue(ctx, traceKey{}, name), span
+}
+
+func (r *recordingTracer) Inject(ctx context.Context) map[string]string {
+	parent, _ := ctx.Value(traceKey{}).(string)
+	return map[string]string{"parent": parent}
+}
+
+func (r *recordingTracer) Extract(ctx context.Context, carrier map[string]string) context.Context {
+	if parent, ok := carrier["parent"]; ok {
+		return context.WithValue(ctx, traceKey{}, "remote "+parent)
+	}
+	return ctx
+}
+
+func (r *recordingTracer) find(name string) *recordedSpan {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, span := range r.spans {
+		if span.name == name {
+			return span
+		}
+	}
+	return nil
+}
+
+func TestCache_TracingGet(t *testing.T) {
+	tracer := &recordingTracer{}
+	c := NewCache("tracingCache", "255.255.255.255", ":12381")
+	defer c.StopListener()
+	c.Tracer = tracer
+	c.Filler = func(key string) (interface{}, error) {
+		return nil, errors.New("fill failed")
+	}
+	ctx := context.WithValue(context.Background(), traceKey{}, "request")
+	c.GetContext(ctx, "key")
+
+	get := tracer.find(SpanGet)
+	if get == nil || get.parent != "request" || get.attributes["dcache.hit"] != false {
+		t.Fatalf("Expected a Get span child of the request, got %+v", get)
+	}
+	if _, ok := get.attributes["dcache.lock_wait"]; !ok {
+		t.Errorf("Expected the lock wait in the Get span")
+	}
+	filler := tracer.find(SpanFiller)
+	if filler == nil || filler.parent != SpanGet || filler.err == nil {
+		t.Errorf("Expected a failed Filler span child of the Get span, got %+v", filler)
+	}
+}
+
+func TestCache_TracingReplication(t *testing.T) {
+	tracer := &recordingTracer{}
+	c := NewCache("tracingCache", "255.255.255.255", ":12382")
+	defer c.StopListener()
+	c.Tracer = tracer
+	c.Set("key", "value")
+	send := tracer.find(SpanSend)
+	if send == nil || send.parent != SpanSet {
+		t.Fatalf("Expected a send span child of the Set span, got %+v", send)
+	}
+
+	// the trace context travels in the envelope
+	written := &message{CacheName: "tracingCache", Key: "key", Value: "value", Trace: map[string]string{"parent": SpanSend}}
+	data, err := written.toUDP()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var received message
+	if err := received.fromUDP(data); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	c.handleMessage(&received)
+	apply := tracer.find(SpanApply)
+	if apply == nil || apply.parent != "remote "+SpanSend {
+		t.Errorf("Expected an apply span child of the remote write, got %+v", apply)
+	}
+}
+
+func TestCache_TracingSendEndsAfterWrite(t *testing.T) {
+	tracer := &recordingTracer{}
+	c := NewCache("tracingCache", "255.255.255.255", ":12528")
+	defer c.StopListener()
+	conn := &recordingConn{}
+	c.senderOnce.Do(func() { c.startSender(conn) })
+	c.Tracer = tracer
+	c.FlushInterval = 100 * time.Millisecond
+	ctx := context.WithValue(context.Background(), traceKey{}, "request")
+	c.SetContext(ctx, "key", "value")
+	c.DeleteContext(ctx, "key")
+	c.IncrContext(ctx, "counter", 1)
+	c.CleanContext(ctx)
+
+	for _, name := range []string{SpanDelete, SpanMerge, SpanClean} {
+		if span := tracer.find(name); span == nil || span.parent != "request" {
+			t.Errorf("Expected a %s span child of the request, got %+v", name, span)
+		}
+	}
+	send := tracer.find(SpanSend)
+	if send == nil || send.ended.Load() {
+		t.Fatalf("Expected a send span that has not ended before the write, got %+v", send)
+	}
+	time.Sleep(300 * time.Millisecond)
+	if len(conn.received()) == 0 {
+		t.Fatalf("Expected the messages to be written")
+	}
+	tracer.mutex.Lock()
+	defer tracer.mutex.Unlock()
+	sends := 0
+	for _, span := range tracer.spans {
+		if span.name != SpanSend {
+			continue
+		}
+		sends++
+		if !span.ended.Load() {
+			t.Errorf("Expected the send span of %s to end after the write", span.parent)
+		}
+	}
+	if sends != 4 {
+		t.Errorf("Expected 4 send spans, got %d", sends)
+	}
+}
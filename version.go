@@ -0,0 +1,193 @@
+package distributed_cache
+
+// In this file, you can find the versions of the entries.
+// Every write gets a version from a Lamport clock of the cache and the node
+// that wrote it, so the nodes apply the writes in the same order:
+// a write is applied only if its version is newer than the local one.
+// The version of a deleted key is kept as a tombstone for the ConflictWindow,
+// so an older write delayed in the network does not bring the key back.
+// Conditional writes also carry the version they were based on, so a write
+// that lost a race against another conditional write is rejected everywhere.
+
+import (
+	"bytes"
+	"github.com/google/uuid"
+	"slices"
+	"time"
+)
+
+// entryMeta is the version of the last write of an entry
+type entryMeta struct {
+	Version     uint64    // Lamport clock of the write
+	Node        uuid.UUID // node that wrote the entry
+	Base        uint64    // version the conditional write was based on
+	Conditional bool      // true when the entry was written by a conditional write
+}
+
+// tombstone is the version of a deleted key and the time it is forgotten
+type tombstone struct {
+	meta    entryMeta
+	expires time.Time
+}
+
+// olderThan returns true when the entry is older than the given version and node
+func (e entryMeta) olderThan(version uint64, node uuid.UUID) bool {
+	if version != e.Version {
+		return version > e.Version
+	}
+	return bytes.Compare(node[:], e.Node[:]) > 0
+}
+
+// tick advances the clock of the cache, the mutex must be locked
+func (c *Cache) tick() uint64 {
+	c.clock++
+	return c.clock
+}
+
+// observe advances the clock of the cache to a received version,
+// the mutex must be locked
+func (c *Cache) observe(version uint64) {
+	if version > c.clock {
+		c.clock = version
+	}
+}
+
+// touch records a local write of a key and returns its version,
+// the mutex must be locked
+func (c *Cache) touch(key string, base uint64, conditional bool) uint64 {
+	version := c.tick()
+	c.setMeta(key, entryMeta{Version: version, Node: c.node, Base: base, Conditional: conditional})
+	return version
+}
+
+// setMeta records the version of an entry, the mutex must be locked
+func (c *Cache) setMeta(key string, meta entryMeta) {
+	if c.meta == nil {
+		c.meta = make(map[string]entryMeta)
+	}
+	c.meta[key] = meta
+	delete(c.tombstones, key)
+	if c.wal != nil {
+		c.appendRecord(record{op: recordMeta, key: key, meta: meta})
+	}
+}
+
+// deleted records a local delete of a key and returns its version,
+// the mutex must be locked
+func (c *Cache) deleted(key string) uint64 {
+	version := c.tick()
+	c.forget(key)
+	if c.owns(key) {
+		c.bury(key, entryMeta{Version: version, Node: c.node})
+	}
+	return version
+}
+
+// bury keeps the version of a deleted key for the ConflictWindow
+// and forgets the expired tombstones, the mutex must be locked
+func (c *Cache) bury(key string, meta entryMeta) {
+	now := time.Now()
+	for len(c.buried) > 0 {
+		dead, ok := c.tombstones[c.buried[0]]
+		if ok && dead.expires.After(now) {
+			break
+		}
+		if ok {
+			delete(c.tombstones, c.buried[0])
+		}
+		c.buried = c.buried[1:]
+	}
+	if c.tombstones == nil {
+		c.tombstones = make(map[string]tombstone)
+	}
+	if _, ok := c.tombstones[key]; ok {
+		// the key is moved to the end of the queue
+		c.buried = slices.DeleteFunc(c.buried, func(buried string) bool { return buried == key })
+	}
+	c.tombstones[key] = tombstone{meta: meta, expires: now.Add(c.getConflictWindow())}
+	c.buried = append(c.buried, key)
+}
+
+// lastWrite returns the versions of the last write and the last delete
+// of a key that are still known, the mutex must be locked
+func (c *Cache) lastWrite(key string) (entryMeta, entryMeta) {
+	return c.meta[key], c.tombstones[key].meta
+}
+
+// buriedMeta returns the version of a key deleted within the ConflictWindow,
+// the mutex must be locked
+func (c *Cache) buriedMeta(key string) (entryMeta, bool) {
+	dead, ok := c.tombstones[key]
+	if !ok || time.Now().After(dead.expires) {
+		return entryMeta{}, false
+	}
+	return dead.meta, true
+}
+
+// accepts returns true when a received write must be applied,
+// the mutex must be locked
+func (c *Cache) accepts(message *message) bool {
+	if message.Version == 0 {
+		// written by a node that does not send versions
+		return true
+	}
+	current, exists := c.meta[message.Key]
+	if !exists {
+		// a write older than a recent delete is rejected
+		if dead, buried := c.buriedMeta(message.Key); buried && !dead.olderThan(message.Version, message.Node) {
+			return false
+		}
+	}
+	if !message.Conditional {
+		return !exists || current.olderThan(message.Version, message.Node)
+	}
+	if !exists {
+		return message.Base == 0
+	}
+	if current.Version == message.Base {
+		return true
+	}
+	// another conditional write based on the same version was applied first,
+	// the newest one wins in every node
+	return current.Conditional && current.Base == message.Base &&
+		current.olderThan(message.Version, message.Node)
+}
+
+// applyRemote applies a set or delete received from another node
+func (c *Cache) applyRemote(message *message) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entries := c.getEntries()
+	c.observe(message.Version)
+	if message.Merge {
+		c.applyMerge(message)
+		return
+	}
+	if !c.owns(message.Key) {
+		entries.remove(message.Key)
+		_, tracked := c.meta[message.Key]
+		if c.pending[message.Key] && message.Version > 0 && (!tracked || c.accepts(message)) {
+			// a strong conditional write of this node checks it after its window
+			c.setMeta(message.Key, entryMeta{Version: message.Version, Node: message.Node,
+				Base: message.Base, Conditional: message.Conditional})
+		}
+		return
+	}
+	if !c.accepts(message) {
+		return
+	}
+	if message.Value == nil {
+		entries.remove(message.Key)
+		c.forget(message.Key)
+		if message.Version > 0 {
+			c.bury(message.Key, entryMeta{Version: message.Version, Node: message.Node,
+				Base: message.Base, Conditional: message.Conditional})
+		}
+		return
+	}
+	entries.store(message.Key, message.Value)
+	if message.Version > 0 {
+		c.setMeta(message.Key, entryMeta{Version: message.Version, Node: message.Node,
+			Base: message.Base, Conditional: message.Conditional})
+	}
+}
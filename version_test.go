@@ -0,0 +1,78 @@
+package distributed_cache
+
+import (
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func TestVersion_Accepts(t *testing.T) {
+	node := uuid.New()
+	c := &Cache{storage: make(map[string]interface{})}
+	c.setMeta("key", entryMeta{Version: 5, Node: node})
+
+	tests := []struct {
+		name    string
+		message message
+		want    bool
+	}{
+		{"unversioned", message{Key: "key"}, true},
+		{"newer", message{Key: "key", Version: 6, Node: node}, true},
+		{"older", message{Key: "key", Version: 4, Node: node}, false},
+		{"new key", message{Key: "other", Version: 1, Node: node}, true},
+		{"conditional on current version", message{Key: "key", Version: 6, Base: 5, Conditional: true}, true},
+		{"conditional on old version", message{Key: "key", Version: 6, Base: 4, Conditional: true}, false},
+		{"conditional on missing key", message{Key: "other", Version: 6, Base: 4, Conditional: true}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.accepts(&tt.message); got != tt.want {
+				t.Errorf("accepts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVersion_ApplyRemoteIgnoresOlderWrites(t *testing.T) {
+	c := &Cache{storage: make(map[string]interface{})}
+	node := uuid.New()
+	c.applyRemote(&message{Key: "key", Value: "new", Version: 2, Node: node})
+	c.applyRemote(&message{Key: "key", Value: "old", Version: 1, Node: node})
+
+	if val, _ := c.load("key"); val != "new" {
+		t.Errorf("Expected new, got %v", val)
+	}
+	if c.clock != 2 {
+		t.Errorf("Expected clock 2, got %v", c.clock)
+	}
+}
+
+func TestVersion_ApplyRemoteKeepsDeletes(t *testing.T) {
+	c := &Cache{storage: make(map[string]interface{}), ConflictWindow: time.Hour}
+	node := uuid.New()
+	c.applyRemote(&message{Key: "key", Value: "value", Version: 1, Node: node})
+	c.applyRemote(&message{Key: "key", Version: 3, Node: node})
+	c.applyRemote(&message{Key: "key", Value: "delayed", Version: 2, Node: node})
+	if val, exists := c.load("key"); exists {
+		t.Errorf("Expected the key to be deleted, got %v", val)
+	}
+	c.applyRemote(&message{Key: "key", Value: "new", Version: 4, Node: node})
+	if val, _ := c.load("key"); val != "new" {
+		t.Errorf("Expected new, got %v", val)
+	}
+
+	c.remove("key")
+	c.deleted("key")
+	c.applyRemote(&message{Key: "key", Value: "delayed", Version: 4, Node: node})
+	if val, exists := c.load("key"); exists {
+		t.Errorf("Expected the local delete to win, got %v", val)
+	}
+
+	c.ConflictWindow = time.Nanosecond
+	c.applyRemote(&message{Key: "other", Version: 10, Node: node})
+	time.Sleep(time.Millisecond)
+	c.applyRemote(&message{Key: "other", Value: "value", Version: 9, Node: node})
+	if val, _ := c.load("other"); val != "value" {
+		t.Errorf("Expected the tombstone to expire, got %v", val)
+	}
+}
@@ -0,0 +1,540 @@
+package distributed_cache
+
+// In this file, you can find the write-ahead log of the caches.
+// EnableLog appends every change of the entries, local or received from other
+// nodes, to a log that is replayed when the cache starts, so a restarted node
+// does not lose the writes made after its last snapshot. The log is compacted
+// periodically into a snapshot: the log is moved to a .old file while the
+// snapshot is taken and removed once the snapshot is written, so a crash at any
+// point leaves a snapshot and logs that rebuild the entries.
+// The records keep the versions of the entries and the deadline of the ones
+// that expire, so the entries that expired while the node was stopped are not replayed.
+// WithLog enables the log in New, before the node receives any change.
+// A record that was not completely written when the node crashed is skipped.
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// SyncPolicy is when the log is flushed to the disk
+type SyncPolicy uint8
+
+const (
+	SyncAlways   SyncPolicy = iota // SyncAlways flushes the log after every record
+	SyncInterval                   // SyncInterval flushes the log every LogOptions.SyncInterval
+	SyncNever                      // SyncNever lets the operating system flush the log
+)
+
+// DefaultLogSyncInterval is the time between the flushes of SyncInterval
+const DefaultLogSyncInterval = 100 * time.Millisecond
+
+// DefaultCompactInterval is the time between the compactions of the log
+const DefaultCompactInterval = 5 * time.Minute
+
+// maxRecordSize is the size over which a record is considered corrupted
+const maxRecordSize = 64 << 20
+
+// Names of the files of the log directory
+const (
+	logFile      = "wal"
+	oldLogFile   = "wal.old"
+	snapshotFile = "snapshot"
+)
+
+// ErrLogDisabled is returned by Compact when the log is not enabled
+var ErrLogDisabled = errors.New("log not enabled")
+
+// ErrLogClosed is returned when a record is appended after the cache was closed
+var ErrLogClosed = errors.New("log closed")
+
+// LogOptions are the options of EnableLog
+type LogOptions struct {
+	Sync            SyncPolicy    // When the log is flushed, SyncAlways by default
+	SyncInterval    time.Duration // Time between the flushes of SyncInterval, DefaultLogSyncInterval when 0
+	CompactInterval time.Duration // Time between the compactions, DefaultCompactInterval when 0
+}
+
+// recordOp is the change of a log record
+type recordOp uint8
+
+const (
+	recordSet recordOp = iota + 1
+	recordDelete
+	recordClean
+	recordExpire // sets the deadline of a key with Expire
+	recordMeta   // sets the version of a key
+)
+
+// Flags of a log record
+const (
+	flagMerge    = 1 << iota // the value is a CRDT encoded with the CRDT codec
+	flagDeadline             // the record has the deadline of the entry
+)
+
+// record is a change of the entries appended to the log
+type record struct {
+	op       recordOp
+	key      string
+	value    interface{} // value of recordSet
+	deadline time.Time   // time the entry expires, zero when it does not expire
+	meta     entryMeta   // version of recordMeta
+}
+
+// wal is the write-ahead log of a cache
+type wal struct {
+	mutex        sync.Mutex
+	compactMutex sync.Mutex // serializes the compactions
+	dir          string
+	file         *os.File
+	policy       SyncPolicy
+	dirty        bool // there are records that were not flushed
+	done         chan struct{}
+}
+
+// loggedEntries appends the changes to the log before applying them
+type loggedEntries struct {
+	entries
+	cache *Cache
+}
+
+func (l loggedEntries) store(key string, value interface{}) {
+	l.entries.store(key, value)
+	// appended after the store to know the deadline, both under the mutex
+	l.cache.appendRecord(record{op: recordSet, key: key, value: value, deadline: l.cache.deadlineOf(key)})
+}
+
+func (l loggedEntries) remove(key string) {
+	l.cache.appendRecord(record{op: recordDelete, key: key})
+	l.entries.remove(key)
+}
+
+func (l loggedEntries) removeAll() {
+	l.cache.appendRecord(record{op: recordClean})
+	l.entries.removeAll()
+}
+
+// deadlineOf returns the time a key expires, zero when the cache type
+// does not expire its keys, the mutex must be locked
+func (c *Cache) deadlineOf(key string) time.Time {
+	if expiring, ok := c.baseEntries().(expiring); ok {
+		return expiring.deadline(key)
+	}
+	return time.Time{}
+}
+
+// EnableLog restores the snapshot and replays the log of dir, compacts them and
+// appends every following change to the log. The cache is locked until the log
+// is enabled, use WithLog to enable it before the cache receives any change
+func (c *Cache) EnableLog(dir string, options LogOptions) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.wal != nil {
+		return errors.New("log already enabled")
+	}
+	s, values, err := readSnapshotFile(c.getCodec(), filepath.Join(dir, snapshotFile))
+	if err == nil {
+		c.restoreSnapshot(s, values)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	for _, name := range []string{oldLogFile, logFile} {
+		if err := c.replay(filepath.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+	// the replayed changes are compacted, so the log starts empty
+	if s, err = c.takeSnapshot(); err != nil {
+		return err
+	}
+	err = writeFileAtomic(filepath.Join(dir, snapshotFile), func(out io.Writer) error {
+		return gob.NewEncoder(out).Encode(s)
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(dir, oldLogFile)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Join(dir, logFile), os.O_CREATE|os.O_TRUNC|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	c.wal = &wal{dir: dir, file: file, policy: options.Sync, done: make(chan struct{})}
+	go c.runLog(c.context, c.wal, options)
+	return nil
+}
+
+// runLog flushes and compacts the log w until the context is done
+func (c *Cache) runLog(ctx context.Context, w *wal, options LogOptions) {
+	defer close(w.done)
+	var syncTick <-chan time.Time
+	if options.Sync == SyncInterval {
+		interval := options.SyncInterval
+		if interval <= 0 {
+			interval = DefaultLogSyncInterval
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		syncTick = ticker.C
+	}
+	compactInterval := options.CompactInterval
+	if compactInterval <= 0 {
+		compactInterval = DefaultCompactInterval
+	}
+	compactTicker := time.NewTicker(compactInterval)
+	defer compactTicker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := w.close(); err != nil {
+				c.logError("closing the log failed", err, "dir", w.dir)
+			}
+			return
+		case <-syncTick:
+			if err := w.sync(); err != nil {
+				c.logError("log sync failed", err, "dir", w.dir)
+			}
+		case <-compactTicker.C:
+			if err := c.Compact(); err != nil {
+				c.logError("log compaction failed", err, "dir", w.dir)
+			}
+		}
+	}
+}
+
+// appendRecord appends a change to the log, the mutex must be locked
+func (c *Cache) appendRecord(r record) {
+	data, err := encodeRecord(c.getCodec(), r)
+	if err == nil {
+		err = c.wal.append(data)
+	}
+	if err != nil {
+		c.logError("log append failed", err, "key", r.key)
+	}
+}
+
+// encodeRecord encodes a record as its size, its checksum and its payload:
+// the change, the codec, the flags, the deadline when it has one,
+// the key size, the key and the value
+func encodeRecord(codec Codec, r record) ([]byte, error) {
+	var data []byte
+	var flags byte
+	if r.op == recordMeta {
+		data = encodeMeta(r.meta)
+	}
+	if r.op == recordSet {
+		var merge bool
+		var err error
+		data, merge, err = encodeValue(codec, r.value)
+		if err != nil {
+			return nil, fmt.Errorf("log of %s: %w", r.key, err)
+		}
+		if merge {
+			flags |= flagMerge
+		}
+	}
+	if !r.deadline.IsZero() {
+		flags |= flagDeadline
+	}
+	payload := make([]byte, 0, 3+2*binary.MaxVarintLen64+len(r.key)+len(data))
+	payload = append(payload, byte(r.op), codec.ID(), flags)
+	if flags&flagDeadline != 0 {
+		payload = binary.AppendVarint(payload, r.deadline.UnixNano())
+	}
+	payload = binary.AppendUvarint(payload, uint64(len(r.key)))
+	payload = append(payload, r.key...)
+	payload = append(payload, data...)
+	encoded := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint32(encoded[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(encoded[4:8], crc32.ChecksumIEEE(payload))
+	return append(encoded, payload...), nil
+}
+
+// encodeMeta encodes the version of an entry as the version, the node,
+// the base and if the write was conditional
+func encodeMeta(meta entryMeta) []byte {
+	data := make([]byte, 0, 2*binary.MaxVarintLen64+len(meta.Node)+1)
+	data = binary.AppendUvarint(data, meta.Version)
+	data = append(data, meta.Node[:]...)
+	data = binary.AppendUvarint(data, meta.Base)
+	if meta.Conditional {
+		return append(data, 1)
+	}
+	return append(data, 0)
+}
+
+// decodeMeta decodes a version encoded with encodeMeta
+func decodeMeta(data []byte) (entryMeta, error) {
+	var meta entryMeta
+	reader := bytes.NewReader(data)
+	var err error
+	if meta.Version, err = binary.ReadUvarint(reader); err != nil {
+		return entryMeta{}, errors.New("invalid record version")
+	}
+	if _, err := io.ReadFull(reader, meta.Node[:]); err != nil {
+		return entryMeta{}, errors.New("invalid record node")
+	}
+	if meta.Base, err = binary.ReadUvarint(reader); err != nil {
+		return entryMeta{}, errors.New("invalid record base")
+	}
+	conditional, err := reader.ReadByte()
+	if err != nil {
+		return entryMeta{}, errors.New("invalid record conditional")
+	}
+	meta.Conditional = conditional == 1
+	return meta, nil
+}
+
+// replay applies the records of a log file to the entries,
+// the records after a torn or corrupted record are skipped.
+// The mutex must be locked
+func (c *Cache) replay(path string) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	codec := c.getCodec()
+	now := time.Now()
+	for offset := 0; offset < len(data); {
+		payload, ok := readRecord(data[offset:])
+		if !ok {
+			c.logWarn("skipping torn log record", nil, "path", path, "offset", offset)
+			return nil
+		}
+		offset += 8 + len(payload)
+		if err := c.applyRecord(codec, payload, now); err != nil {
+			return fmt.Errorf("log %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// readRecord returns the payload of the record at the start of data,
+// false when the record is incomplete or its checksum does not match
+func readRecord(data []byte) ([]byte, bool) {
+	if len(data) < 8 {
+		return nil, false
+	}
+	size := binary.BigEndian.Uint32(data[0:4])
+	if size > maxRecordSize || uint64(len(data)-8) < uint64(size) {
+		return nil, false
+	}
+	payload := data[8 : 8+size]
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(data[4:8]) {
+		return nil, false
+	}
+	return payload, true
+}
+
+// applyRecord applies the payload of a record to the entries, the records
+// with a deadline before now remove the key, the mutex must be locked
+func (c *Cache) applyRecord(codec Codec, payload []byte, now time.Time) error {
+	if len(payload) < 3 {
+		return errors.New("record too short")
+	}
+	op, codecID, flags := recordOp(payload[0]), payload[1], payload[2]
+	reader := bytes.NewReader(payload[3:])
+	var deadline time.Time
+	if flags&flagDeadline != 0 {
+		nanos, err := binary.ReadVarint(reader)
+		if err != nil {
+			return errors.New("invalid record deadline")
+		}
+		deadline = time.Unix(0, nanos)
+	}
+	size, err := binary.ReadUvarint(reader)
+	if err != nil || size > uint64(reader.Len()) {
+		return errors.New("invalid record key")
+	}
+	rest := payload[len(payload)-reader.Len():]
+	key, data := string(rest[:size]), rest[size:]
+	entries := c.baseEntries()
+	expired := !deadline.IsZero() && now.After(deadline)
+	switch op {
+	case recordSet:
+		merge := flags&flagMerge != 0
+		if !merge && codecID != codec.ID() {
+			return fmt.Errorf("%w: record encoded with %s, cache uses %s",
+				ErrCodecMismatch, codecName(codecID), codec.Name())
+		}
+		if expired {
+			entries.remove(key)
+			return nil
+		}
+		value, err := decodeStored(codec, data, merge)
+		if err != nil {
+			return fmt.Errorf("record of %s: %w", key, err)
+		}
+		entries.store(key, value)
+		if expiring, ok := entries.(expiring); ok && !deadline.IsZero() {
+			expiring.setDeadline(key, deadline)
+		}
+	case recordExpire:
+		if expirable, ok := entries.(expirable); ok && !expired {
+			expirable.expire(key, deadline)
+		} else {
+			entries.remove(key)
+		}
+	case recordMeta:
+		meta, err := decodeMeta(data)
+		if err != nil {
+			return err
+		}
+		c.observe(meta.Version)
+		if _, exists := c.storage[key]; exists {
+			c.setMeta(key, meta)
+		}
+	case recordDelete:
+		entries.remove(key)
+	case recordClean:
+		entries.removeAll()
+	default:
+		return fmt.Errorf("unknown record %d", op)
+	}
+	return nil
+}
+
+// append writes a record to the log
+func (w *wal) append(record []byte) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.file == nil {
+		return ErrLogClosed
+	}
+	if _, err := w.file.Write(record); err != nil {
+		return err
+	}
+	if w.policy == SyncAlways {
+		return w.file.Sync()
+	}
+	w.dirty = true
+	return nil
+}
+
+// sync flushes the records that were not flushed
+func (w *wal) sync() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.file == nil || !w.dirty {
+		return nil
+	}
+	w.dirty = false
+	return w.file.Sync()
+}
+
+// close flushes and closes the log
+func (w *wal) close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Sync()
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	w.file = nil
+	return err
+}
+
+// rotate moves the records of the log to the old log and starts an empty log,
+// the records are appended to the old log when a previous compaction did not finish
+func (w *wal) rotate() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.file == nil {
+		return ErrLogClosed
+	}
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.file = nil
+	path, oldPath := filepath.Join(w.dir, logFile), filepath.Join(w.dir, oldLogFile)
+	var err error
+	if _, statErr := os.Stat(oldPath); errors.Is(statErr, os.ErrNotExist) {
+		err = os.Rename(path, oldPath)
+	} else {
+		err = appendFile(oldPath, path)
+	}
+	if err != nil {
+		return err
+	}
+	w.file, err = os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY|os.O_APPEND, 0o644)
+	w.dirty = false
+	return err
+}
+
+// appendFile appends the content of the file src to the file dst
+func appendFile(dst, src string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(dst, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Compact writes a snapshot of the entries and removes the records of the log
+// that it contains
+func (c *Cache) Compact() error {
+	c.mutex.Lock()
+	w := c.wal
+	c.mutex.Unlock()
+	if w == nil {
+		return ErrLogDisabled
+	}
+	w.compactMutex.Lock()
+	defer w.compactMutex.Unlock()
+	c.mutex.Lock()
+	s, err := c.takeSnapshot()
+	if err == nil {
+		err = w.rotate()
+	}
+	c.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	err = writeFileAtomic(filepath.Join(w.dir, snapshotFile), func(out io.Writer) error {
+		return gob.NewEncoder(out).Encode(s)
+	})
+	if err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join(w.dir, oldLogFile))
+}
@@ -0,0 +1,241 @@
+package distributed_cache
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCache_LogReplay(t *testing.T) {
+	dir := t.TempDir()
+	first := NewLRUCache("logReplay", "255.255.255.255", ":12366", 10)
+	if err := first.EnableLog(dir, LogOptions{}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	first.Set("old", "value")
+	first.Clean()
+	first.Set("key1", "value1")
+	first.Set("key2", "value2")
+	first.Delete("key2")
+	first.Incr("counter", 3)
+	// the remote writes are logged too
+	first.handleMessage(&message{CacheName: "logReplay", Node: uuid.New(), Key: "remote", Value: "value",
+		Version: 100})
+	first.Close()
+
+	second := NewLRUCache("logReplay", "255.255.255.255", ":12367", 10)
+	defer second.Close()
+	if err := second.EnableLog(dir, LogOptions{}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := map[string]interface{}{"key1": "value1", "remote": "value"}
+	for key, value := range expected {
+		if val := second.Get(key); val != value {
+			t.Errorf("Expected %v for %s, got %v", value, key, val)
+		}
+	}
+	for _, key := range []string{"old", "key2"} {
+		if val := second.Get(key); val != nil {
+			t.Errorf("Expected %s to be deleted, got %v", key, val)
+		}
+	}
+	if val := second.Counter("counter"); val != 3 {
+		t.Errorf("Expected counter 3, got %v", val)
+	}
+}
+
+func TestCache_LogTornRecord(t *testing.T) {
+	dir := t.TempDir()
+	first := NewCache("logTorn", "255.255.255.255", ":12368")
+	if err := first.EnableLog(dir, LogOptions{Sync: SyncNever}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	first.Set("key1", "value1")
+	first.Set("key2", "value2")
+	path := filepath.Join(dir, logFile)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Expected the log, got %v", err)
+	}
+	first.Set("key3", "value3")
+	first.Close()
+
+	// the node crashed while writing the record of key3
+	if err := os.Truncate(path, info.Size()+12); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	second := NewCache("logTorn", "255.255.255.255", ":12369")
+	if err := second.EnableLog(dir, LogOptions{}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if val := second.Get("key2"); val != "value2" {
+		t.Errorf("Expected value2, got %v", val)
+	}
+	if val := second.Get("key3"); val != nil {
+		t.Errorf("Expected the torn record to be skipped, got %v", val)
+	}
+	// the log starts again after the torn record
+	second.Set("key4", "value4")
+	second.Close()
+
+	third := NewCache("logTorn", "255.255.255.255", ":12370")
+	defer third.Close()
+	if err := third.EnableLog(dir, LogOptions{}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if val := third.Get("key4"); val != "value4" {
+		t.Errorf("Expected value4, got %v", val)
+	}
+}
+
+func TestCache_LogCorruptedRecord(t *testing.T) {
+	dir := t.TempDir()
+	first := NewCache("logCorrupted", "255.255.255.255", ":12371")
+	if err := first.EnableLog(dir, LogOptions{Sync: SyncInterval, SyncInterval: time.Millisecond}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	first.Set("key1", "value1")
+	path := filepath.Join(dir, logFile)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Expected the log, got %v", err)
+	}
+	first.Set("key2", "value2")
+	first.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Expected the log, got %v", err)
+	}
+	// the payload of the record of key2
+	data[info.Size()+8] ^= 0xff
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	second := NewCache("logCorrupted", "255.255.255.255", ":12372")
+	defer second.Close()
+	if err := second.EnableLog(dir, LogOptions{}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if val := second.Get("key1"); val != "value1" {
+		t.Errorf("Expected value1, got %v", val)
+	}
+	if val := second.Get("key2"); val != nil {
+		t.Errorf("Expected the corrupted record to be skipped, got %v", val)
+	}
+}
+
+func TestCache_LogCompact(t *testing.T) {
+	dir := t.TempDir()
+	first := NewCache("logCompact", "255.255.255.255", ":12373")
+	if err := first.EnableLog(dir, LogOptions{}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		first.Set("key1", i)
+	}
+	if err := first.Compact(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if info, err := os.Stat(filepath.Join(dir, logFile)); err != nil || info.Size() != 0 {
+		t.Errorf("Expected an empty log after the compaction, got %v %v", info, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, oldLogFile)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected the old log to be removed, got %v", err)
+	}
+	first.Set("key2", "value2")
+	first.Close()
+
+	second := NewCache("logCompact", "255.255.255.255", ":12374")
+	defer second.Close()
+	if err := second.EnableLog(dir, LogOptions{}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if val := second.Get("key1"); val != 9 {
+		t.Errorf("Expected 9, got %v", val)
+	}
+	if val := second.Get("key2"); val != "value2" {
+		t.Errorf("Expected value2, got %v", val)
+	}
+	disabled := NewCache("logDisabled", "255.255.255.255", ":12375")
+	defer disabled.StopListener()
+	if err := disabled.Compact(); !errors.Is(err, ErrLogDisabled) {
+		t.Errorf("Expected ErrLogDisabled, got %v", err)
+	}
+}
+
+func TestCache_LogExpiry(t *testing.T) {
+	dir := t.TempDir()
+	first := NewLRUCacheWithTTL("logExpiry", "255.255.255.255", ":12500", 10, time.Hour)
+	if err := first.EnableLog(dir, LogOptions{}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	first.Set("short", "value")
+	first.Set("expired", "value")
+	first.Set("long", "value")
+	if _, err := first.Expire("short", 50*time.Millisecond); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := first.Expire("expired", time.Millisecond); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	// the expiration is logged
+	first.mutex.Lock()
+	first.removeExpired()
+	first.mutex.Unlock()
+	first.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	// the deadlines are kept, so the entries do not get a new ttl
+	second := NewLRUCacheWithTTL("logExpiry", "255.255.255.255", ":12501", 10, time.Hour)
+	defer second.Close()
+	if err := second.EnableLog(dir, LogOptions{}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for _, key := range []string{"short", "expired"} {
+		if val := second.Get(key); val != nil {
+			t.Errorf("Expected %s to be expired, got %v", key, val)
+		}
+	}
+	if val := second.Get("long"); val != "value" {
+		t.Errorf("Expected value, got %v", val)
+	}
+}
+
+func TestCache_WithLog(t *testing.T) {
+	dir := t.TempDir()
+	first, err := New("withLog", WithTransport("", ":12502"), WithLog(dir, LogOptions{}))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	first.Set("key1", "value1")
+	first.Set("key1", "value2")
+	first.mutex.Lock()
+	version := first.meta["key1"].Version
+	first.mutex.Unlock()
+	first.Close()
+
+	second, err := New("withLog", WithTransport("", ":12503"), WithLog(dir, LogOptions{}))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer second.Close()
+	if val := second.Get("key1"); val != "value2" {
+		t.Errorf("Expected value2, got %v", val)
+	}
+	// the versions are replayed, so an older remote write is rejected
+	second.handleMessage(&message{CacheName: "withLog", Node: uuid.New(), Key: "key1", Value: "old",
+		Version: version - 1})
+	if val := second.Get("key1"); val != "value2" {
+		t.Errorf("Expected the older write to be rejected, got %v", val)
+	}
+	if err := second.EnableLog(dir, LogOptions{}); err == nil {
+		t.Errorf("Expected an error enabling the log twice")
+	}
+}
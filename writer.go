@@ -0,0 +1,195 @@
+package distributed_cache
+
+// In this file, you can find the Writer interface, the write path symmetric
+// to the Filler. With WriteThrough (default) the Writer is called before the
+// cache is changed and its error is returned by Set and Delete. With WriteBehind
+// the writes are queued, the queue keeps only the last write of each key and it
+// is flushed to the Writer every WriteBehindInterval and when the cache is closed.
+// The writes that still fail after WriteRetries are sent to the DeadLetter hook.
+// Only the local writes are written, the writes received from other nodes are not.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Writer persists the writes of the cache
+type Writer interface {
+	Write(key string, value interface{}) error
+	Delete(key string) error
+}
+
+// WriteMode is when the Writer is called
+type WriteMode uint8
+
+const (
+	WriteThrough WriteMode = iota // WriteThrough calls the Writer in Set and Delete
+	WriteBehind                   // WriteBehind calls the Writer from a coalescing queue
+)
+
+// DefaultWriteBehindInterval is the time between the flushes of the write-behind queue
+const DefaultWriteBehindInterval = 100 * time.Millisecond
+
+// DefaultWriteRetries is the number of retries of a failed write-behind write
+const DefaultWriteRetries = 3
+
+// writeRetryDelay is the delay before the first retry, it doubles on each retry
+const writeRetryDelay = 10 * time.Millisecond
+
+// ErrWriteBehindClosed is returned when a write is queued after the cache was closed
+var ErrWriteBehindClosed = errors.New("write-behind queue closed")
+
+// writeBehind is the coalescing queue of the writes, a nil value is a delete
+type writeBehind struct {
+	mutex   sync.Mutex
+	pending map[string]interface{}
+	closed  bool
+	done    chan struct{}
+	err     error // errors of the last flush, when the cache was closed
+}
+
+// writeOut writes a value to the Backend and the Writer before it is stored,
+// a nil value deletes the key
+func (c *Cache) writeOut(key string, value interface{}) error {
+	if err := c.writeBackend(key, value); err != nil {
+		return err
+	}
+	if c.Writer == nil {
+		return nil
+	}
+	if c.WriteMode == WriteBehind {
+		return c.getWriteBehind().enqueue(key, value)
+	}
+	return c.persist(key, value)
+}
+
+// persist calls the Writer for a value, a nil value deletes the key
+func (c *Cache) persist(key string, value interface{}) error {
+	if value == nil {
+		return c.Writer.Delete(key)
+	}
+	return c.Writer.Write(key, value)
+}
+
+// getWriteBehind returns the write-behind queue, starting it the first time
+func (c *Cache) getWriteBehind() *writeBehind {
+	c.writeBehindOnce.Do(func() {
+		c.writeBehind = &writeBehind{pending: make(map[string]interface{}), done: make(chan struct{})}
+		go c.runWriteBehind(c.context)
+	})
+	return c.writeBehind
+}
+
+// enqueue queues a write, replacing the previous write of the key
+func (w *writeBehind) enqueue(key string, value interface{}) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		return ErrWriteBehindClosed
+	}
+	w.pending[key] = value
+	return nil
+}
+
+// take returns the queued writes and empties the queue
+func (w *writeBehind) take() map[string]interface{} {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	pending := w.pending
+	w.pending = make(map[string]interface{})
+	return pending
+}
+
+// runWriteBehind flushes the queue until the context is done,
+// the writes that are still queued are flushed before returning
+func (c *Cache) runWriteBehind(ctx context.Context) {
+	w := c.writeBehind
+	defer close(w.done)
+	interval := c.WriteBehindInterval
+	if interval <= 0 {
+		interval = DefaultWriteBehindInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			w.mutex.Lock()
+			w.closed = true
+			w.mutex.Unlock()
+			w.err = c.flushWriteBehind(context.Background())
+			return
+		case <-ticker.C:
+			c.flushWriteBehind(ctx)
+		}
+	}
+}
+
+// flushWriteBehind writes the queued writes in key order
+// and returns the errors of the writes sent to the DeadLetter
+func (c *Cache) flushWriteBehind(ctx context.Context) error {
+	var errs []error
+	pending := c.writeBehind.take()
+	for _, key := range sortedKeys(pending) {
+		if err := c.writeRetrying(ctx, key, pending[key]); err != nil {
+			c.deadLetter(key, pending[key], err)
+			errs = append(errs, fmt.Errorf("writing %q: %w", key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// writeRetrying calls the Writer, retrying with a growing delay when it fails
+func (c *Cache) writeRetrying(ctx context.Context, key string, value interface{}) error {
+	retries := c.WriteRetries
+	if retries <= 0 {
+		retries = DefaultWriteRetries
+	}
+	delay := writeRetryDelay
+	err := c.persist(key, value)
+	for i := 0; err != nil && i < retries; i++ {
+		select {
+		case <-ctx.Done():
+		case <-time.After(delay):
+		}
+		delay *= 2
+		err = c.persist(key, value)
+	}
+	return err
+}
+
+// deadLetter hands a write that could not be written to the DeadLetter hook
+func (c *Cache) deadLetter(key string, value interface{}, err error) {
+	if c.DeadLetter == nil {
+		c.logError("write-behind failed", err, "key", key)
+		return
+	}
+	c.DeadLetter(key, value, err)
+}
+
+// Close stops the cache, it flushes the write-behind queue to the Writer,
+// the queued messages to the other nodes, the last snapshot and the log before returning.
+// It returns the errors of the writes of the last write-behind flush
+func (c *Cache) Close() error {
+	c.StopListener()
+	var err error
+	if c.Writer != nil && c.WriteMode == WriteBehind {
+		w := c.getWriteBehind()
+		<-w.done
+		err = w.err
+	}
+	if c.snapshotDone != nil {
+		<-c.snapshotDone
+	}
+	c.mutex.Lock()
+	w := c.wal
+	c.mutex.Unlock()
+	if w != nil {
+		<-w.done
+	}
+	<-c.getSender().done
+	return err
+}
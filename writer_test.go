@@ -0,0 +1,124 @@
+package distributed_cache
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingWriter records the writes and fails the keys in failing
+type recordingWriter struct {
+	mutex   sync.Mutex
+	writes  []string
+	values  map[string]interface{}
+	failing map[string]bool
+}
+
+func newRecordingWriter() *recordingWriter {
+	return &recordingWriter{values: make(map[string]interface{}), failing: make(map[string]bool)}
+}
+
+func (r *recordingWriter) Write(key string, value interface{}) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.writes = append(r.writes, key)
+	if r.failing[key] {
+		return errors.New("write failed")
+	}
+	r.values[key] = value
+	return nil
+}
+
+func (r *recordingWriter) Delete(key string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.writes = append(r.writes, key)
+	if r.failing[key] {
+		return errors.New("delete failed")
+	}
+	delete(r.values, key)
+	return nil
+}
+
+func TestCache_WriteThrough(t *testing.T) {
+	writer := newRecordingWriter()
+	writer.failing["bad"] = true
+	c := NewCache("writeThrough", "255.255.255.255", ":12356")
+	defer c.StopListener()
+	c.Writer = writer
+
+	if err := c.Set("key", "value"); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if writer.values["key"] != "value" {
+		t.Errorf("Expected value in the writer, got %v", writer.values["key"])
+	}
+	if err := c.Set("bad", "value"); err == nil {
+		t.Errorf("Expected the error of the writer")
+	}
+	if val := c.Get("bad"); val != nil {
+		t.Errorf("Expected the failed write not to be cached, got %v", val)
+	}
+	if err := c.SetMany(map[string]interface{}{"key1": "value1", "bad": "value"}); err == nil {
+		t.Errorf("Expected the error of the writer")
+	}
+	if val := c.Get("key1"); val != "value1" {
+		t.Errorf("Expected value1, got %v", val)
+	}
+	if err := c.Delete("key"); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if _, exists := writer.values["key"]; exists {
+		t.Errorf("Expected key to be deleted from the writer")
+	}
+}
+
+func TestCache_WriteBehind(t *testing.T) {
+	writer := newRecordingWriter()
+	writer.failing["bad"] = true
+	var deadLetters []string
+	c := NewCache("writeBehind", "255.255.255.255", ":12357")
+	c.Writer = writer
+	c.WriteMode = WriteBehind
+	c.WriteBehindInterval = time.Hour
+	c.WriteRetries = 1
+	c.DeadLetter = func(key string, value interface{}, err error) {
+		deadLetters = append(deadLetters, key)
+	}
+
+	c.Set("key", "value1")
+	c.Set("key", "value2")
+	c.Set("other", "value")
+	c.Delete("other")
+	c.Set("bad", "value")
+	if len(writer.writes) != 0 {
+		t.Errorf("Expected no writes before the flush, got %v", writer.writes)
+	}
+	// Close returns the error of the last flush
+	if err := c.Close(); err == nil || !strings.Contains(err.Error(), `"bad"`) {
+		t.Errorf("Expected the error of bad, got %v", err)
+	}
+
+	// the writes are coalesced and flushed in key order, the failed write is retried once
+	expected := []string{"bad", "bad", "key", "other"}
+	if len(writer.writes) != len(expected) {
+		t.Fatalf("Expected writes %v, got %v", expected, writer.writes)
+	}
+	for i := range expected {
+		if writer.writes[i] != expected[i] {
+			t.Errorf("Expected writes %v, got %v", expected, writer.writes)
+			break
+		}
+	}
+	if writer.values["key"] != "value2" {
+		t.Errorf("Expected value2 in the writer, got %v", writer.values["key"])
+	}
+	if len(deadLetters) != 1 || deadLetters[0] != "bad" {
+		t.Errorf("Expected bad in the dead letters, got %v", deadLetters)
+	}
+	if err := c.Set("key", "value3"); !errors.Is(err, ErrWriteBehindClosed) {
+		t.Errorf("Expected ErrWriteBehindClosed, got %v", err)
+	}
+}